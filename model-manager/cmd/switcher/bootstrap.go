@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/zheng/homeGPT/internal/switcher"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// runBootstrap loads every model one at a time, puts it to sleep, then wakes the
+// active model(s), replacing the old bootstrap.sh startup sequence
+func runBootstrap(cfg *models.Config, opts []switcher.Option) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts = append(opts, switcher.WithoutBackgroundTasks())
+	sw := switcher.New(cfg, opts...)
+
+	log.Printf("=== homeGPT Bootstrap ===")
+	err := sw.Bootstrap(ctx, func(step switcher.BootstrapStep) {
+		switch step.Action {
+		case "loading":
+			log.Printf("[%d/%d] Loading %s...", step.Index, step.Total, step.ModelID)
+		case "loaded":
+			log.Printf("[%d/%d] %s is loaded", step.Index, step.Total, step.ModelID)
+		case "sleeping":
+			log.Printf("[%d/%d] Putting %s to sleep to free VRAM...", step.Index, step.Total, step.ModelID)
+		case "asleep":
+			log.Printf("[%d/%d] %s is asleep", step.Index, step.Total, step.ModelID)
+		case "waking":
+			log.Printf("Waking active model %s...", step.ModelID)
+		case "ready":
+			log.Printf("Active model %s is ready", step.ModelID)
+		}
+	})
+	if err != nil {
+		log.Fatalf("Bootstrap failed: %v", err)
+	}
+
+	log.Printf("=== Done ===")
+}
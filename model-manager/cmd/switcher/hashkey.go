@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/zheng/homeGPT/internal/handlers"
+)
+
+// runHashKey prints the hash of the key given as an argument, or generates a new
+// key and prints it with its hash, ready to paste into config.yaml
+func runHashKey(args []string) {
+	key := ""
+	if len(args) > 0 {
+		key = args[0]
+	} else {
+		key = handlers.GenerateKey()
+		fmt.Printf("Key:  %s\n", key)
+	}
+	fmt.Printf("Hash: %s\n\n", handlers.HashKey(key))
+	fmt.Printf("auth:\n  keys:\n    - name: my-key\n      hash: %s\n      role: operator\n", handlers.HashKey(key))
+}
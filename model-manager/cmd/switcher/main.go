@@ -1,55 +1,120 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/zheng/homeGPT/internal/audit"
 	"github.com/zheng/homeGPT/internal/config"
 	"github.com/zheng/homeGPT/internal/handlers"
+	"github.com/zheng/homeGPT/internal/metrics"
+	"github.com/zheng/homeGPT/internal/runtime"
+	"github.com/zheng/homeGPT/internal/state"
 	"github.com/zheng/homeGPT/internal/switcher"
+	"github.com/zheng/homeGPT/internal/tracing"
+	"github.com/zheng/homeGPT/pkg/models"
 )
 
 func main() {
-	// Load configuration
-	configPath := os.Getenv("CONFIG_PATH")
-	if configPath == "" {
-		configPath = "/app/config.yaml"
+	// `switcher hash-key` prints a new API key and the hash to put in config.yaml
+	if len(os.Args) > 1 && os.Args[1] == "hash-key" {
+		runHashKey(os.Args[2:])
+		return
 	}
 
-	cfg, err := config.Load(configPath)
+	cfg := loadConfig()
+	opts := containerOptions()
+
+	// `switcher bootstrap` brings every model up from cold and exits
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap" {
+		runBootstrap(cfg, opts)
+		return
+	}
+
+	// Tracing is configured through OTEL_TRACES_EXPORTER and is off by default
+	shutdownTracing, err := tracing.Setup(context.Background())
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		log.Fatalf("Failed to set up tracing: %v", err)
 	}
+	defer shutdownTracing(context.Background())
 
-	log.Printf("Loaded configuration with %d models", len(cfg.Models))
+	store := openStateStore()
+	defer store.Close()
+
+	auditLog := openAuditLog()
+	defer auditLog.Close()
+
+	// Initialize switcher. Bootstrap runs without the circuit breaker, since every
+	// server is expected to be unreachable while it loads from cold.
+	m := metrics.New()
+	sw := switcher.New(cfg, append(opts,
+		switcher.WithMetrics(m),
+		switcher.WithStateStore(store),
+		switcher.WithAuditLog(auditLog),
+		switcher.WithCircuitBreaker())...)
 
-	// Initialize switcher
-	sw := switcher.New(cfg)
+	// API keys from config.yaml, plus ADMIN_TOKEN as an admin key
+	auth := handlers.NewAuthenticator(apiKeys(cfg))
+	if !auth.Enabled() {
+		log.Printf("No API keys configured, the API is open and the admin API is disabled")
+	}
+
+	// Pick up config.yaml edits without a restart
+	watchConfig(configPath(), sw, auth)
 
 	// Initialize handlers
-	h := handlers.New(sw)
+	origins := allowedOrigins()
+	h := handlers.New(sw, handlers.WithConfigPath(configPath()), handlers.WithAllowedOrigins(origins))
 
-	// Setup Gin router
+	// Setup Gin router. Requests are logged with access_token redacted, so
+	// event stream keys don't end up in the logs.
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
-
-	// CORS middleware for internal service
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
-	})
+	r := gin.New()
+	r.Use(handlers.RequestLogger(), gin.Recovery())
+
+	// Browser pages may only call the API from the origins in CORS_ALLOWED_ORIGINS
+	r.Use(handlers.CORS(origins))
 
-	// Routes
+	// Continue callers' traces and make the request span the parent of switch
+	// and upstream vLLM spans
+	r.Use(tracing.Middleware())
+
+	// Health checks and metrics scraping stay open
 	r.GET("/health", h.Health)
-	r.GET("/models", h.GetModels)
-	r.POST("/switch", h.SwitchModel)
+	r.GET("/metrics", gin.WrapH(m.Handler()))
+
+	// Read-only state and real-time model status changes
+	viewer := r.Group("/", auth.Require(models.RoleViewer))
+	viewer.GET("/models", h.GetModels)
+	viewer.GET("/switch/jobs/:id", h.GetSwitchJob)
+	viewer.GET("/switch/history", h.GetSwitchHistory)
+	viewer.GET("/audit", h.GetAudit)
+	viewer.GET("/schedules", h.GetSchedules)
+	viewer.GET("/v1/models", h.ListModels)
+
+	// Event streams also take the key as an access_token query parameter, since
+	// browser EventSource and WebSocket clients can't set headers
+	stream := r.Group("/", auth.RequireStream(models.RoleViewer))
+	stream.GET("/events", h.Events)
+	stream.GET("/ws", h.WebSocket)
+
+	// Switching models and OpenAI-compatible inference routes, forwarded to the
+	// requested model
+	operator := r.Group("/", auth.Require(models.RoleOperator))
+	operator.POST("/switch", h.SwitchModel)
+	operator.DELETE("/switch", h.CancelSwitch)
+	operator.DELETE("/switch/jobs/:id", h.CancelSwitchJob)
+	operator.POST("/v1/chat/completions", h.ChatCompletions)
+	operator.POST("/v1/completions", h.Completions)
+
+	// Runtime model registration
+	admin := r.Group("/admin", auth.Require(models.RoleAdmin))
+	admin.POST("/models/:id", h.CreateModel)
+	admin.PUT("/models/:id", h.UpdateModel)
+	admin.DELETE("/models/:id", h.DeleteModel)
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -62,3 +127,97 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// configPath returns the configuration file path from CONFIG_PATH
+func configPath() string {
+	path := os.Getenv("CONFIG_PATH")
+	if path == "" {
+		path = "/app/config.yaml"
+	}
+	return path
+}
+
+// apiKeys returns the API keys from the config, plus ADMIN_TOKEN as an admin key
+// named "admin-token" when it is set
+func apiKeys(cfg *models.Config) []models.APIKey {
+	keys := append([]models.APIKey(nil), cfg.Auth.Keys...)
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		keys = append(keys, models.APIKey{Name: "admin-token", Hash: handlers.HashKey(token), Role: models.RoleAdmin})
+	}
+	return keys
+}
+
+// allowedOrigins returns the comma-separated origins in CORS_ALLOWED_ORIGINS
+func allowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// loadConfig loads the configuration from CONFIG_PATH
+func loadConfig() *models.Config {
+	cfg, err := config.Load(configPath())
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	log.Printf("Loaded configuration with %d models", len(cfg.Models))
+	return cfg
+}
+
+// containerOptions enables starting and stopping model containers when the
+// Docker socket is mounted
+func containerOptions() []switcher.Option {
+	dockerSocket := os.Getenv("DOCKER_SOCKET")
+	if dockerSocket == "" {
+		dockerSocket = runtime.DefaultDockerSocket
+	}
+
+	if _, err := os.Stat(dockerSocket); err != nil {
+		log.Printf("Docker socket %s not available, disabled models cannot be started on demand", dockerSocket)
+		return nil
+	}
+
+	log.Printf("Using Docker Engine API at %s to manage model containers", dockerSocket)
+	return []switcher.Option{switcher.WithContainerRuntime(runtime.NewDocker(dockerSocket))}
+}
+
+// openStateStore opens the state file at STATE_PATH so restarts restore the
+// active models and switch history, falling back to in-memory state
+func openStateStore() state.Store {
+	statePath := os.Getenv("STATE_PATH")
+	if statePath == "" {
+		statePath = "/app/state/state.db"
+	}
+
+	store, err := state.OpenBolt(statePath)
+	if err != nil {
+		log.Printf("Warning: %v; state will not survive restarts", err)
+		return state.NewMemory()
+	}
+
+	log.Printf("Persisting state in %s", statePath)
+	return store
+}
+
+// openAuditLog opens the audit log at AUDIT_PATH, rotated by size. Without it
+// switches are still logged, just not in the audit log.
+func openAuditLog() *audit.Log {
+	auditPath := os.Getenv("AUDIT_PATH")
+	if auditPath == "" {
+		auditPath = "/app/state/audit.jsonl"
+	}
+
+	l, err := audit.Open(auditPath, audit.DefaultMaxBytes, audit.DefaultBackups)
+	if err != nil {
+		log.Printf("Warning: %v; audit log disabled", err)
+		return nil
+	}
+
+	log.Printf("Writing audit log to %s", auditPath)
+	return l
+}
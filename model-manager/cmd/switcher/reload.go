@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/zheng/homeGPT/internal/config"
+	"github.com/zheng/homeGPT/internal/handlers"
+	"github.com/zheng/homeGPT/internal/switcher"
+)
+
+// watchConfig reloads the configuration when the file at path changes or the
+// process receives SIGHUP. A config that fails validation is logged and
+// rejected, and the current one stays in effect. API key changes apply to the
+// next request.
+func watchConfig(path string, sw *switcher.Switcher, auth *handlers.Authenticator) {
+	reload := func(reason string) {
+		log.Printf("Reloading config from %s (%s)", path, reason)
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			log.Printf("Config reload rejected, keeping the current config: %v", err)
+			return
+		}
+		if err := sw.Reload(context.Background(), cfg); err != nil {
+			log.Printf("Config reload rejected, keeping the current config: %v", err)
+			return
+		}
+		auth.SetKeys(apiKeys(cfg))
+	}
+
+	if err := config.Watch(context.Background(), path, func() { reload("file changed") }); err != nil {
+		log.Printf("Warning: not watching %s for changes, send SIGHUP to reload: %v", path, err)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reload("SIGHUP")
+		}
+	}()
+}
@@ -0,0 +1,241 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+const (
+	// DefaultMaxBytes is the size at which the log file is rotated
+	DefaultMaxBytes = 10 << 20
+	// DefaultBackups is how many rotated files are kept
+	DefaultBackups = 5
+)
+
+// Log is an append-only audit log in a JSON lines file. When the file would
+// grow past maxBytes it is renamed to path.1, older files shift up to
+// path.<backups>, and the oldest is dropped. A nil *Log discards entries.
+type Log struct {
+	path     string
+	maxBytes int64
+	backups  int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Filter selects audit entries. Zero fields match everything.
+type Filter struct {
+	ModelID string    // Entries about this model, including switches away from it
+	Since   time.Time // Entries at or after this time
+	Limit   int       // Maximum entries returned
+}
+
+// Open opens (creating if needed) the audit log at path. Zero maxBytes or
+// backups fall back to DefaultMaxBytes and DefaultBackups.
+func Open(path string, maxBytes int64, backups int) (*Log, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if backups <= 0 {
+		backups = DefaultBackups
+	}
+
+	l := &Log{path: path, maxBytes: maxBytes, backups: backups}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// open opens the current log file for appending
+func (l *Log) open() error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log %s: %w", l.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open audit log %s: %w", l.path, err)
+	}
+
+	l.file = f
+	l.size = info.Size()
+
+	// Finish a line cut short by a crash, so the next entry starts on its own line
+	if l.size > 0 {
+		last := make([]byte, 1)
+		if r, err := os.Open(l.path); err == nil {
+			r.ReadAt(last, l.size-1)
+			r.Close()
+		}
+		if last[0] != '\n' {
+			n, _ := f.Write([]byte{'\n'})
+			l.size += int64(n)
+		}
+	}
+	return nil
+}
+
+// Append writes an entry to the log, rotating it first if it is full
+func (l *Log) Append(entry models.AuditEntry) error {
+	if l == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return fmt.Errorf("audit log %s is closed", l.path)
+	}
+	if l.size > 0 && l.size+int64(len(line)) > l.maxBytes {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// rotate shifts the backups up by one, moves the current file to path.1 and
+// starts a new one
+func (l *Log) rotate() error {
+	l.file.Close()
+	l.file = nil
+
+	os.Remove(l.backupPath(l.backups))
+	for i := l.backups - 1; i >= 1; i-- {
+		os.Rename(l.backupPath(i), l.backupPath(i+1))
+	}
+	if err := os.Rename(l.path, l.backupPath(1)); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	return l.open()
+}
+
+// backupPath returns the path of the i-th most recent rotated file
+func (l *Log) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", l.path, i)
+}
+
+// Query returns the entries matching filter, newest first, reading the rotated
+// files as well as the current one
+func (l *Log) Query(filter Filter) ([]models.AuditEntry, error) {
+	if l == nil {
+		return []models.AuditEntry{}, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Oldest file first, so entries come out in the order they were written
+	var entries []models.AuditEntry
+	for i := l.backups; i >= 0; i-- {
+		path := l.path
+		if i > 0 {
+			path = l.backupPath(i)
+		}
+		read, err := readEntries(path, filter)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, read...)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
+
+// readEntries returns the entries of one log file that match filter, skipping
+// lines that don't parse (such as one cut short by a crash)
+func readEntries(path string, filter Filter) ([]models.AuditEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer f.Close()
+
+	var entries []models.AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var entry models.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// matches reports whether entry passes the filter
+func (f Filter) matches(entry models.AuditEntry) bool {
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if f.ModelID == "" || entry.ModelID == f.ModelID {
+		return true
+	}
+	for _, id := range entry.From {
+		if id == f.ModelID {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+func TestLog_AppendAndQuery(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "audit.jsonl"), 0, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer l.Close()
+
+	base := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	entries := []models.AuditEntry{
+		{Time: base, Action: models.AuditSwitch, Actor: "ops", ModelID: "model-b", From: []string{"model-a"}, Success: true},
+		{Time: base.Add(time.Minute), Action: models.AuditResync, Actor: "system", ModelID: "model-c", OldStatus: models.StatusSleeping, NewStatus: models.StatusActive, Success: true},
+		{Time: base.Add(2 * time.Minute), Action: models.AuditSwitch, Actor: "ops", ModelID: "model-a", From: []string{"model-b"}, RolledBack: true, Error: "wake failed"},
+	}
+	for _, e := range entries {
+		if err := l.Append(e); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	all, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(all) != 3 || all[0].ModelID != "model-a" || !all[0].RolledBack || all[2].ModelID != "model-b" {
+		t.Errorf("expected all 3 entries newest first, got %+v", all)
+	}
+
+	// Switches away from a model count as entries about it
+	got, _ := l.Query(Filter{ModelID: "model-a"})
+	if len(got) != 2 {
+		t.Errorf("expected 2 entries involving model-a, got %+v", got)
+	}
+
+	got, _ = l.Query(Filter{Since: base.Add(30 * time.Second)})
+	if len(got) != 2 {
+		t.Errorf("expected 2 entries since the first, got %+v", got)
+	}
+
+	got, _ = l.Query(Filter{Limit: 1})
+	if len(got) != 1 || got[0].ModelID != "model-a" {
+		t.Errorf("expected only the newest entry, got %+v", got)
+	}
+}
+
+func TestLog_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := Open(path, 200, 2)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer l.Close()
+
+	base := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		entry := models.AuditEntry{Time: base.Add(time.Duration(i) * time.Second), Action: models.AuditSwitch, Actor: "ops", ModelID: "model-a", Success: true}
+		if err := l.Append(entry); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("expected %s to exist, got %v", p, err)
+		}
+		if info.Size() > 200 {
+			t.Errorf("expected %s to stay under 200 bytes, got %d", p, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups to be kept, got %v", err)
+	}
+
+	// The newest entries survive rotation, in order
+	got, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(got) == 0 || !got[0].Time.Equal(base.Add(9*time.Second)) {
+		t.Errorf("expected the newest entry first, got %+v", got)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Time.After(got[i-1].Time) {
+			t.Fatalf("expected entries newest first, got %+v", got)
+		}
+	}
+}
+
+func TestLog_ReopenKeepsEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, _ := Open(path, 0, 0)
+	l.Append(models.AuditEntry{Time: time.Now(), Action: models.AuditSwitch, Actor: "ops", ModelID: "model-a", Success: true})
+	l.Close()
+
+	// A line cut short by a crash is skipped
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"time":"2025-`)
+	f.Close()
+
+	l, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer l.Close()
+	l.Append(models.AuditEntry{Time: time.Now(), Action: models.AuditSwitch, Actor: "ops", ModelID: "model-b", Success: true})
+
+	got, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(got) != 2 || got[0].ModelID != "model-b" || got[1].ModelID != "model-a" {
+		t.Errorf("expected the entries written before and after reopening, got %+v", got)
+	}
+}
+
+func TestLog_NilDiscards(t *testing.T) {
+	var l *Log
+	if err := l.Append(models.AuditEntry{ModelID: "model-a"}); err != nil {
+		t.Errorf("expected nil log to discard entries, got %v", err)
+	}
+	if got, err := l.Query(Filter{}); err != nil || len(got) != 0 {
+		t.Errorf("expected no entries from nil log, got %v %v", got, err)
+	}
+}
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"encoding/hex"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/zheng/homeGPT/pkg/models"
 	"gopkg.in/yaml.v3"
@@ -20,24 +22,219 @@ func Load(path string) (*models.Config, error) {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
-	// Validate configuration
+	if err := Validate(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate checks a configuration, whether loaded from a file or built at runtime
+func Validate(cfg *models.Config) error {
 	if len(cfg.Models) == 0 {
-		return nil, fmt.Errorf("no models defined in config")
+		return fmt.Errorf("no models defined in config")
+	}
+
+	if cfg.GPUMemoryBudgetGB < 0 {
+		return fmt.Errorf("gpu_memory_budget_gb must not be negative, got %.1f", cfg.GPUMemoryBudgetGB)
 	}
 
 	activeCount := 0
+	var activeMemoryGB float64
+	seen := make(map[string]bool, len(cfg.Models))
 	for i := range cfg.Models {
-		if cfg.Models[i].StartupMode == "" {
-			return nil, fmt.Errorf("model %s: startup_mode must be specified (disabled, sleep, or active)", cfg.Models[i].ID)
+		if cfg.Models[i].ID == "" {
+			return fmt.Errorf("model %d: id must be specified", i)
+		}
+		if seen[cfg.Models[i].ID] {
+			return fmt.Errorf("duplicate model id %s", cfg.Models[i].ID)
+		}
+		seen[cfg.Models[i].ID] = true
+		if cfg.Models[i].Port <= 0 {
+			return fmt.Errorf("model %s: port must be positive, got %d", cfg.Models[i].ID, cfg.Models[i].Port)
+		}
+		switch cfg.Models[i].StartupMode {
+		case models.StartupDisabled, models.StartupSleep, models.StartupActive:
+		case "":
+			return fmt.Errorf("model %s: startup_mode must be specified (disabled, sleep, or active)", cfg.Models[i].ID)
+		default:
+			return fmt.Errorf("model %s: invalid startup_mode '%s' (must be disabled, sleep, or active)",
+				cfg.Models[i].ID, cfg.Models[i].StartupMode)
 		}
 		if cfg.Models[i].StartupMode == models.StartupActive {
 			activeCount++
+			activeMemoryGB += cfg.Models[i].GPUMemoryGB
+		}
+		if cfg.GPUMemoryBudgetGB > 0 && cfg.Models[i].GPUMemoryGB > cfg.GPUMemoryBudgetGB {
+			return fmt.Errorf("model %s: gpu_memory_gb %.1f exceeds gpu_memory_budget_gb %.1f",
+				cfg.Models[i].ID, cfg.Models[i].GPUMemoryGB, cfg.GPUMemoryBudgetGB)
+		}
+		if cfg.Models[i].IdleTimeout < 0 {
+			return fmt.Errorf("model %s: idle_timeout must not be negative", cfg.Models[i].ID)
+		}
+		if cfg.Models[i].MinResidency < 0 {
+			return fmt.Errorf("model %s: min_residency must not be negative", cfg.Models[i].ID)
 		}
 	}
 
-	if activeCount != 1 {
-		return nil, fmt.Errorf("exactly one model must have startup_mode='active', found %d", activeCount)
+	if err := validateDevices(cfg); err != nil {
+		return err
 	}
 
-	return &cfg, nil
+	if err := validateKeys(cfg.Auth.Keys); err != nil {
+		return err
+	}
+
+	if err := validateSchedules(cfg); err != nil {
+		return err
+	}
+
+	if cfg.GPUMemoryBudgetGB > 0 || len(cfg.GPUs) > 0 {
+		// With a budget, several models may start awake as long as they fit together
+		if activeCount == 0 {
+			return fmt.Errorf("at least one model must have startup_mode='active'")
+		}
+		if cfg.GPUMemoryBudgetGB > 0 && activeMemoryGB > cfg.GPUMemoryBudgetGB {
+			return fmt.Errorf("models with startup_mode='active' need %.1f GB, exceeding gpu_memory_budget_gb %.1f",
+				activeMemoryGB, cfg.GPUMemoryBudgetGB)
+		}
+	} else if activeCount != 1 {
+		return fmt.Errorf("exactly one model must have startup_mode='active', found %d", activeCount)
+	}
+
+	if cfg.Queue.MaxDepth < 0 {
+		return fmt.Errorf("queue.max_depth must not be negative, got %d", cfg.Queue.MaxDepth)
+	}
+	if cfg.Queue.MaxWaitSeconds < 0 {
+		return fmt.Errorf("queue.max_wait_seconds must not be negative, got %d", cfg.Queue.MaxWaitSeconds)
+	}
+	if cfg.CircuitBreaker.FailureThreshold < 0 {
+		return fmt.Errorf("circuit_breaker.failure_threshold must not be negative, got %d", cfg.CircuitBreaker.FailureThreshold)
+	}
+	if cfg.CircuitBreaker.OpenSeconds < 0 {
+		return fmt.Errorf("circuit_breaker.open_seconds must not be negative, got %d", cfg.CircuitBreaker.OpenSeconds)
+	}
+	if cfg.Thrash.WindowSeconds < 0 {
+		return fmt.Errorf("thrash_detection.window_seconds must not be negative, got %d", cfg.Thrash.WindowSeconds)
+	}
+	if cfg.Thrash.MaxSwitches < 0 {
+		return fmt.Errorf("thrash_detection.max_switches must not be negative, got %d", cfg.Thrash.MaxSwitches)
+	}
+	if cfg.Thrash.BackoffSeconds < 0 {
+		return fmt.Errorf("thrash_detection.backoff_seconds must not be negative, got %d", cfg.Thrash.BackoffSeconds)
+	}
+
+	return nil
+}
+
+// validateKeys checks that API keys have unique names, a SHA-256 hash and a known role
+func validateKeys(keys []models.APIKey) error {
+	names := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		if key.Name == "" {
+			return fmt.Errorf("auth key %d: name must be specified", i+1)
+		}
+		if names[key.Name] {
+			return fmt.Errorf("auth key %s is declared more than once", key.Name)
+		}
+		names[key.Name] = true
+
+		digest, ok := strings.CutPrefix(key.Hash, "sha256:")
+		if _, err := hex.DecodeString(digest); !ok || err != nil || len(digest) != 64 {
+			return fmt.Errorf("auth key %s: hash must be sha256: followed by 64 hex digits (see `switcher hash-key`)", key.Name)
+		}
+		if !key.Role.Valid() {
+			return fmt.Errorf("auth key %s: invalid role '%s' (must be viewer, operator, or admin)", key.Name, key.Role)
+		}
+	}
+	return nil
+}
+
+// validateSchedules checks that schedules have unique names, a valid cron
+// expression and time zone, and a known action and model
+func validateSchedules(cfg *models.Config) error {
+	ids := make(map[string]bool, len(cfg.Models))
+	for i := range cfg.Models {
+		ids[cfg.Models[i].ID] = true
+	}
+
+	names := make(map[string]bool, len(cfg.Schedules))
+	for i := range cfg.Schedules {
+		sched := &cfg.Schedules[i]
+		if sched.Name == "" {
+			return fmt.Errorf("schedule %d: name must be specified", i+1)
+		}
+		if names[sched.Name] {
+			return fmt.Errorf("schedule %s is declared more than once", sched.Name)
+		}
+		names[sched.Name] = true
+
+		if _, err := sched.Parse(); err != nil {
+			return fmt.Errorf("schedule %s: %w", sched.Name, err)
+		}
+
+		switch sched.GetAction() {
+		case models.ScheduleSwitch:
+			if !ids[sched.ModelID] {
+				return fmt.Errorf("schedule %s: model_id '%s' is not a configured model", sched.Name, sched.ModelID)
+			}
+		case models.ScheduleSleep:
+			if sched.ModelID != "" {
+				return fmt.Errorf("schedule %s: model_id is not used by action sleep", sched.Name)
+			}
+		default:
+			return fmt.Errorf("schedule %s: invalid action '%s' (must be switch or sleep)", sched.Name, sched.Action)
+		}
+	}
+	return nil
+}
+
+// validateDevices checks the per-GPU pool: device IDs are unique, every model's
+// gpus are declared, and each model (and the models starting active together)
+// fits on its devices
+func validateDevices(cfg *models.Config) error {
+	capacity := make(map[int]float64, len(cfg.GPUs))
+	for _, dev := range cfg.GPUs {
+		if _, dup := capacity[dev.ID]; dup {
+			return fmt.Errorf("gpu %d is declared more than once", dev.ID)
+		}
+		if dev.MemoryGB <= 0 {
+			return fmt.Errorf("gpu %d: memory_gb must be positive", dev.ID)
+		}
+		capacity[dev.ID] = dev.MemoryGB
+	}
+
+	activeUsage := make(map[int]float64, len(cfg.GPUs))
+	for i := range cfg.Models {
+		m := &cfg.Models[i]
+		if len(cfg.GPUs) == 0 {
+			continue
+		}
+
+		devices := m.GPUs
+		if len(devices) == 0 {
+			for _, dev := range cfg.GPUs {
+				devices = append(devices, dev.ID)
+			}
+		}
+
+		share := m.DeviceShareGB(len(cfg.GPUs))
+		for _, id := range devices {
+			memoryGB, ok := capacity[id]
+			if !ok {
+				return fmt.Errorf("model %s: gpu %d is not declared under gpus", m.ID, id)
+			}
+			if share > memoryGB {
+				return fmt.Errorf("model %s: needs %.1f GB on gpu %d, which has %.1f GB", m.ID, share, id, memoryGB)
+			}
+			if m.StartupMode == models.StartupActive {
+				activeUsage[id] += share
+				if activeUsage[id] > memoryGB {
+					return fmt.Errorf("models with startup_mode='active' need %.1f GB on gpu %d, which has %.1f GB",
+						activeUsage[id], id, memoryGB)
+				}
+			}
+		}
+	}
+
+	return nil
 }
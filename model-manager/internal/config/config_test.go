@@ -3,7 +3,11 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
 )
 
 func TestLoad_Success(t *testing.T) {
@@ -263,3 +267,504 @@ models:
 		t.Errorf("expected startup_mode 'disabled' for model-b, got '%s'", cfg.Models[1].StartupMode)
 	}
 }
+
+func TestLoad_QueueConfig(t *testing.T) {
+	content := `
+models:
+  - id: model-a
+    container_name: "vllm-a"
+    port: 8000
+    startup_mode: active
+queue:
+  max_depth: 8
+  max_wait_seconds: 120
+`
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if cfg.Queue.MaxDepth != 8 {
+		t.Errorf("expected queue max_depth 8, got %d", cfg.Queue.MaxDepth)
+	}
+
+	if cfg.Queue.MaxWaitSeconds != 120 {
+		t.Errorf("expected queue max_wait_seconds 120, got %d", cfg.Queue.MaxWaitSeconds)
+	}
+}
+
+func TestLoad_NegativeQueueDepth(t *testing.T) {
+	content := `
+models:
+  - id: model-a
+    container_name: "vllm-a"
+    port: 8000
+    startup_mode: active
+queue:
+  max_depth: -1
+`
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	_, err := Load(configPath)
+	if err == nil {
+		t.Fatal("expected error for negative queue max_depth")
+	}
+}
+
+func TestLoad_CircuitBreakerConfig(t *testing.T) {
+	content := `
+models:
+  - id: model-a
+    container_name: "vllm-a"
+    port: 8000
+    startup_mode: active
+circuit_breaker:
+  failure_threshold: 3
+  open_seconds: 10
+`
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if cfg.CircuitBreaker.FailureThreshold != 3 || cfg.CircuitBreaker.OpenSeconds != 10 {
+		t.Errorf("expected failure_threshold 3 and open_seconds 10, got %+v", cfg.CircuitBreaker)
+	}
+}
+
+func TestLoad_AuthKeys(t *testing.T) {
+	hash := "sha256:" + strings.Repeat("ab", 32)
+	content := `
+models:
+  - id: model-a
+    container_name: "vllm-a"
+    port: 8000
+    startup_mode: active
+auth:
+  keys:
+    - name: dashboard
+      hash: ` + hash + `
+      role: viewer
+`
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(cfg.Auth.Keys) != 1 || cfg.Auth.Keys[0].Name != "dashboard" || cfg.Auth.Keys[0].Hash != hash || cfg.Auth.Keys[0].Role != models.RoleViewer {
+		t.Errorf("expected the dashboard viewer key, got %+v", cfg.Auth.Keys)
+	}
+}
+
+func TestValidate_InvalidAuthKeys(t *testing.T) {
+	hash := "sha256:" + strings.Repeat("ab", 32)
+	tests := map[string][]models.APIKey{
+		"missing name":   {{Hash: hash, Role: models.RoleViewer}},
+		"duplicate name": {{Name: "a", Hash: hash, Role: models.RoleViewer}, {Name: "a", Hash: hash, Role: models.RoleAdmin}},
+		"plaintext key":  {{Name: "a", Hash: "secret", Role: models.RoleViewer}},
+		"short hash":     {{Name: "a", Hash: "sha256:abcd", Role: models.RoleViewer}},
+		"unknown role":   {{Name: "a", Hash: hash, Role: "root"}},
+	}
+
+	for name, keys := range tests {
+		cfg := &models.Config{
+			Models: []models.Model{{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive}},
+			Auth:   models.AuthConfig{Keys: keys},
+		}
+		if err := Validate(cfg); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestLoad_IdleTimeout(t *testing.T) {
+	content := `
+models:
+  - id: model-a
+    container_name: "vllm-a"
+    port: 8000
+    startup_mode: active
+    idle_timeout: 30m
+  - id: model-b
+    container_name: "vllm-b"
+    port: 8000
+    startup_mode: sleep
+`
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if cfg.Models[0].IdleTimeout != 30*time.Minute {
+		t.Errorf("expected idle_timeout 30m, got %s", cfg.Models[0].IdleTimeout)
+	}
+
+	if cfg.Models[1].IdleTimeout != 0 {
+		t.Errorf("expected no idle_timeout for model-b, got %s", cfg.Models[1].IdleTimeout)
+	}
+}
+
+func TestLoad_MinResidencyAndThrashDetection(t *testing.T) {
+	content := `
+models:
+  - id: model-a
+    container_name: "vllm-a"
+    port: 8000
+    startup_mode: active
+    min_residency: 5m
+thrash_detection:
+  window_seconds: 300
+  max_switches: 6
+  backoff_seconds: 120
+`
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if cfg.Models[0].MinResidency != 5*time.Minute {
+		t.Errorf("expected min_residency 5m, got %s", cfg.Models[0].MinResidency)
+	}
+	want := models.ThrashConfig{WindowSeconds: 300, MaxSwitches: 6, BackoffSeconds: 120}
+	if cfg.Thrash != want {
+		t.Errorf("expected thrash_detection %+v, got %+v", want, cfg.Thrash)
+	}
+}
+
+func TestValidate_NegativeCooldowns(t *testing.T) {
+	tests := map[string]func(cfg *models.Config){
+		"min_residency":   func(cfg *models.Config) { cfg.Models[0].MinResidency = -time.Minute },
+		"window_seconds":  func(cfg *models.Config) { cfg.Thrash.WindowSeconds = -1 },
+		"max_switches":    func(cfg *models.Config) { cfg.Thrash.MaxSwitches = -1 },
+		"backoff_seconds": func(cfg *models.Config) { cfg.Thrash.BackoffSeconds = -1 },
+	}
+
+	for name, mutate := range tests {
+		cfg := &models.Config{
+			Models: []models.Model{{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive}},
+		}
+		mutate(cfg)
+		if err := Validate(cfg); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestValidate_InvalidModels(t *testing.T) {
+	tests := map[string][]models.Model{
+		"missing id": {{ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive}},
+		"duplicate id": {
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive},
+			{ID: "model-a", ContainerName: "vllm-b", Port: 8001, StartupMode: models.StartupSleep},
+		},
+		"missing port":  {{ID: "model-a", ContainerName: "vllm-a", StartupMode: models.StartupActive}},
+		"negative port": {{ID: "model-a", ContainerName: "vllm-a", Port: -1, StartupMode: models.StartupActive}},
+	}
+
+	for name, ms := range tests {
+		if err := Validate(&models.Config{Models: ms}); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestLoad_Schedules(t *testing.T) {
+	content := `
+models:
+  - id: model-a
+    container_name: "vllm-a"
+    port: 8000
+    startup_mode: active
+schedules:
+  - name: work-hours
+    cron: "0 8 * * 1-5"
+    timezone: Europe/Berlin
+    model_id: model-a
+  - name: night
+    cron: "30 23 * * *"
+    timezone: Europe/Berlin
+    action: sleep
+`
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(cfg.Schedules) != 2 {
+		t.Fatalf("expected 2 schedules, got %d", len(cfg.Schedules))
+	}
+	if work := cfg.Schedules[0]; work.GetAction() != models.ScheduleSwitch || work.ModelID != "model-a" || work.Timezone != "Europe/Berlin" {
+		t.Errorf("expected work-hours to switch to model-a, got %+v", work)
+	}
+	if night := cfg.Schedules[1]; night.GetAction() != models.ScheduleSleep {
+		t.Errorf("expected night to sleep, got %+v", night)
+	}
+}
+
+func TestValidate_InvalidSchedules(t *testing.T) {
+	tests := map[string][]models.Schedule{
+		"missing name":     {{Cron: "@daily", ModelID: "model-a"}},
+		"duplicate name":   {{Name: "a", Cron: "@daily", ModelID: "model-a"}, {Name: "a", Cron: "@hourly", ModelID: "model-a"}},
+		"bad cron":         {{Name: "a", Cron: "every morning", ModelID: "model-a"}},
+		"bad timezone":     {{Name: "a", Cron: "@daily", Timezone: "Mars/Olympus", ModelID: "model-a"}},
+		"unknown model":    {{Name: "a", Cron: "@daily", ModelID: "model-z"}},
+		"sleep with model": {{Name: "a", Cron: "@daily", Action: models.ScheduleSleep, ModelID: "model-a"}},
+		"unknown action":   {{Name: "a", Cron: "@daily", Action: "reboot"}},
+	}
+
+	for name, schedules := range tests {
+		cfg := &models.Config{
+			Models:    []models.Model{{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive}},
+			Schedules: schedules,
+		}
+		if err := Validate(cfg); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestLoad_GPUMemoryBudget(t *testing.T) {
+	content := `
+gpu_memory_budget_gb: 24
+models:
+  - id: model-a
+    container_name: "vllm-a"
+    port: 8000
+    gpu_memory_gb: 8
+    startup_mode: active
+  - id: model-b
+    container_name: "vllm-b"
+    port: 8000
+    gpu_memory_gb: 12
+    startup_mode: active
+  - id: model-c
+    container_name: "vllm-c"
+    port: 8000
+    gpu_memory_gb: 16
+    startup_mode: sleep
+`
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if cfg.GPUMemoryBudgetGB != 24 {
+		t.Errorf("expected gpu_memory_budget_gb 24, got %.1f", cfg.GPUMemoryBudgetGB)
+	}
+}
+
+func TestLoad_GPUMemoryBudgetExceeded(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "active models exceed budget",
+			content: `
+gpu_memory_budget_gb: 16
+models:
+  - id: model-a
+    container_name: "vllm-a"
+    port: 8000
+    gpu_memory_gb: 8
+    startup_mode: active
+  - id: model-b
+    container_name: "vllm-b"
+    port: 8000
+    gpu_memory_gb: 12
+    startup_mode: active
+`,
+		},
+		{
+			name: "single model exceeds budget",
+			content: `
+gpu_memory_budget_gb: 16
+models:
+  - id: model-a
+    container_name: "vllm-a"
+    port: 8000
+    gpu_memory_gb: 8
+    startup_mode: active
+  - id: model-b
+    container_name: "vllm-b"
+    port: 8000
+    gpu_memory_gb: 20
+    startup_mode: sleep
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configPath := filepath.Join(tmpDir, "config.yaml")
+
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to write test config: %v", err)
+			}
+
+			if _, err := Load(configPath); err == nil {
+				t.Error("expected error for config exceeding gpu_memory_budget_gb, got nil")
+			}
+		})
+	}
+}
+
+func TestLoad_GPUDevices(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name: "models on separate devices both active",
+			content: `
+gpus:
+  - id: 0
+    memory_gb: 48
+  - id: 1
+    memory_gb: 48
+models:
+  - id: model-a
+    container_name: "vllm-a"
+    port: 8000
+    gpu_memory_gb: 40
+    gpus: [0]
+    startup_mode: active
+  - id: model-b
+    container_name: "vllm-b"
+    port: 8000
+    gpu_memory_gb: 40
+    gpus: [1]
+    startup_mode: active
+`,
+		},
+		{
+			name: "undeclared device",
+			content: `
+gpus:
+  - id: 0
+    memory_gb: 48
+models:
+  - id: model-a
+    container_name: "vllm-a"
+    port: 8000
+    gpu_memory_gb: 40
+    gpus: [1]
+    startup_mode: active
+`,
+			wantErr: true,
+		},
+		{
+			name: "active models overflow a device",
+			content: `
+gpus:
+  - id: 0
+    memory_gb: 48
+  - id: 1
+    memory_gb: 48
+models:
+  - id: model-a
+    container_name: "vllm-a"
+    port: 8000
+    gpu_memory_gb: 40
+    gpus: [0]
+    startup_mode: active
+  - id: model-b
+    container_name: "vllm-b"
+    port: 8000
+    gpu_memory_gb: 60
+    gpus: [0, 1]
+    startup_mode: active
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configPath := filepath.Join(tmpDir, "config.yaml")
+
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to write test config: %v", err)
+			}
+
+			cfg, err := Load(configPath)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(cfg.GPUs) != 2 || cfg.Models[1].GPUs[0] != 1 {
+				t.Errorf("unexpected gpu config: %+v / %+v", cfg.GPUs, cfg.Models[1].GPUs)
+			}
+		})
+	}
+}
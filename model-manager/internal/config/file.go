@@ -0,0 +1,211 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/zheng/homeGPT/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// SaveModel writes a model into the config file at path, replacing the entry
+// with the same ID or appending a new one. The rest of the file, including its
+// comments, is left as it is.
+func SaveModel(path string, model *models.Model) error {
+	return editModels(path, func(list *yaml.Node) error {
+		node, err := modelNode(model)
+		if err != nil {
+			return err
+		}
+
+		if i := findModel(list, model.ID); i >= 0 {
+			mergeMapping(list.Content[i], node)
+		} else {
+			list.Content = append(list.Content, node)
+		}
+		return nil
+	})
+}
+
+// mergeMapping updates existing to hold the keys and values of updated, keeping
+// the key order, comments and styles of the keys it already had
+func mergeMapping(existing, updated *yaml.Node) {
+	content := make([]*yaml.Node, 0, len(updated.Content))
+	for i := 0; i+1 < len(existing.Content); i += 2 {
+		key, value := existing.Content[i], existing.Content[i+1]
+		next := mappingValue(updated, key.Value)
+		if next == nil {
+			continue
+		}
+		if next.Kind == value.Kind && next.Tag == value.Tag {
+			next.Style = value.Style
+		}
+		next.HeadComment, next.LineComment, next.FootComment = value.HeadComment, value.LineComment, value.FootComment
+		content = append(content, key, next)
+	}
+	for i := 0; i+1 < len(updated.Content); i += 2 {
+		if mappingValue(existing, updated.Content[i].Value) == nil {
+			content = append(content, updated.Content[i], updated.Content[i+1])
+		}
+	}
+	existing.Content = content
+}
+
+// DeleteModel removes a model from the config file at path
+func DeleteModel(path string, id string) error {
+	return editModels(path, func(list *yaml.Node) error {
+		i := findModel(list, id)
+		if i < 0 {
+			return fmt.Errorf("model %s is not in %s", id, path)
+		}
+		list.Content = append(list.Content[:i], list.Content[i+1:]...)
+		return nil
+	})
+}
+
+// editModels applies edit to the models list of the config file, then replaces
+// the file atomically
+func editModels(path string, edit func(list *yaml.Node) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("config file %s is not a YAML mapping", path)
+	}
+
+	list := mappingValue(doc.Content[0], "models")
+	if list == nil || list.Kind != yaml.SequenceNode {
+		return fmt.Errorf("config file %s has no models list", path)
+	}
+	if err := edit(list); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	enc.Close()
+
+	return writeAtomic(path, buf.Bytes())
+}
+
+// writeAtomic replaces the file at path by writing a temporary file in the same
+// directory and renaming it over the original, so readers never see a partial file
+func writeAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace config file: %w", err)
+	}
+	return nil
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// findModel returns the index of the model with the given ID in the models list, or -1
+func findModel(list *yaml.Node, id string) int {
+	for i, item := range list.Content {
+		if item.Kind != yaml.MappingNode {
+			continue
+		}
+		if v := mappingValue(item, "id"); v != nil && v.Value == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// modelNode encodes a model as a YAML mapping, leaving out unset fields so the
+// entry reads like a hand-written one
+func modelNode(model *models.Model) (*yaml.Node, error) {
+	var node yaml.Node
+	if err := node.Encode(model); err != nil {
+		return nil, fmt.Errorf("failed to encode model %s: %w", model.ID, err)
+	}
+
+	pruneEmpty(&node)
+
+	// Write device lists inline, like gpus: [0, 1]
+	if gpus := mappingValue(&node, "gpus"); gpus != nil {
+		gpus.Style = yaml.FlowStyle
+	}
+	return &node, nil
+}
+
+// pruneEmpty drops the keys of a mapping, and of mappings nested in it, whose
+// values are empty
+func pruneEmpty(mapping *yaml.Node) {
+	content := make([]*yaml.Node, 0, len(mapping.Content))
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		value := mapping.Content[i+1]
+		if value.Kind == yaml.MappingNode {
+			pruneEmpty(value)
+		}
+		if isEmptyNode(value) {
+			continue
+		}
+		content = append(content, mapping.Content[i], value)
+	}
+	mapping.Content = content
+}
+
+// isEmptyNode reports whether an encoded value is null, zero or empty
+func isEmptyNode(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.SequenceNode, yaml.MappingNode:
+		return len(n.Content) == 0
+	case yaml.ScalarNode:
+		switch n.Tag {
+		case "!!null":
+			return true
+		case "!!int", "!!float":
+			return n.Value == "0"
+		case "!!str":
+			return n.Value == "" || n.Value == "0s"
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+const fileTestConfig = `# Model Manager Configuration
+models:
+  - id: model-a
+    name: "Model A"
+    container_name: vllm-a
+    port: 8000  # Internal container port
+    gpu_memory_gb: 24.0
+    gpus: [0, 1]
+    startup_mode: active
+
+# Queue settings
+queue:
+  max_depth: 8
+`
+
+func writeFileTestConfig(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(fileTestConfig), 0640); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestSaveModel_Appends(t *testing.T) {
+	path := writeFileTestConfig(t)
+
+	model := &models.Model{
+		ID:            "model-b",
+		ContainerName: "vllm-b",
+		Port:          8000,
+		GPUs:          []int{1},
+		StartupMode:   models.StartupSleep,
+		IdleTimeout:   10 * time.Minute,
+		Container:     &models.ContainerConfig{Image: "vllm/vllm-openai:latest"},
+	}
+	if err := SaveModel(path, model); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("expected saved config to load, got %v", err)
+	}
+	if len(cfg.Models) != 2 {
+		t.Fatalf("expected 2 models, got %d", len(cfg.Models))
+	}
+	saved := &cfg.Models[1]
+	if saved.ID != "model-b" || saved.IdleTimeout != 10*time.Minute || saved.Container == nil || saved.Container.Image != "vllm/vllm-openai:latest" {
+		t.Errorf("unexpected saved model: %+v", saved)
+	}
+	if cfg.Queue.MaxDepth != 8 {
+		t.Errorf("expected other settings to be kept, got queue %+v", cfg.Queue)
+	}
+
+	data, _ := os.ReadFile(path)
+	text := string(data)
+	for _, want := range []string{"# Model Manager Configuration", "# Queue settings", "gpus: [1]"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected saved file to contain %q, got:\n%s", want, text)
+		}
+	}
+	// Unset fields are left out
+	if strings.Contains(text, "host_port") || strings.Contains(text, "null") {
+		t.Errorf("expected unset fields to be omitted, got:\n%s", text)
+	}
+
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("expected file mode 0640 to be kept, got %o", info.Mode().Perm())
+	}
+}
+
+func TestSaveModel_ReplacesKeepingComments(t *testing.T) {
+	path := writeFileTestConfig(t)
+
+	model := &models.Model{
+		ID:            "model-a",
+		Name:          "Model A",
+		ContainerName: "vllm-a",
+		Port:          8001,
+		GPUMemoryGB:   30,
+		GPUs:          []int{0, 1},
+		StartupMode:   models.StartupActive,
+	}
+	if err := SaveModel(path, model); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("expected saved config to load, got %v", err)
+	}
+	if len(cfg.Models) != 1 || cfg.Models[0].Port != 8001 || cfg.Models[0].GPUMemoryGB != 30 {
+		t.Errorf("expected model-a to be replaced, got %+v", cfg.Models)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "port: 8001 # Internal container port") {
+		t.Errorf("expected line comment to be kept, got:\n%s", data)
+	}
+	if !strings.Contains(string(data), "gpus: [0, 1]") {
+		t.Errorf("expected inline gpus to stay inline, got:\n%s", data)
+	}
+}
+
+func TestDeleteModel(t *testing.T) {
+	path := writeFileTestConfig(t)
+
+	if err := SaveModel(path, &models.Model{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupSleep}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := DeleteModel(path, "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("expected config to load, got %v", err)
+	}
+	if len(cfg.Models) != 1 || cfg.Models[0].ID != "model-a" {
+		t.Errorf("expected only model-a left, got %+v", cfg.Models)
+	}
+
+	if err := DeleteModel(path, "unknown"); err == nil {
+		t.Error("expected error deleting an unknown model")
+	}
+}
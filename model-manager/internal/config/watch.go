@@ -0,0 +1,74 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce coalesces the bursts of events an editor produces when saving
+const watchDebounce = 500 * time.Millisecond
+
+// Watch calls onChange after the file at path is written, created or replaced,
+// until ctx is done. The parent directory is watched as well as the file, so
+// saves that replace the file by renaming over it are picked up too.
+func Watch(ctx context.Context, path string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+
+	path = filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", filepath.Dir(path), err)
+	}
+	// Watching the file itself catches in-place writes to a bind-mounted file,
+	// which don't always show up as directory events
+	if err := watcher.Add(path); err != nil {
+		log.Printf("Warning: watching only the directory of %s: %v", path, err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var timer *time.Timer
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != path || !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					continue
+				}
+				// A replaced file drops its watch; add it back for the new inode
+				if event.Has(fsnotify.Create) {
+					watcher.Add(path)
+				}
+
+				if timer == nil {
+					timer = time.AfterFunc(watchDebounce, onChange)
+				} else {
+					timer.Reset(watchDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("config watcher error: %v", err)
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatch_CallsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("models: []\n"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+	if err := Watch(ctx, path, func() { calls.Add(1) }); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// Several writes in a burst are reported once
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(path, []byte("models: []\n"), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+	}
+	waitForCalls(t, &calls, 1)
+
+	// Replacing the file by renaming over it is picked up as well
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte("models: []\n"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("failed to replace config: %v", err)
+	}
+	waitForCalls(t, &calls, 2)
+}
+
+func TestWatch_IgnoresOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("models: []\n"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+	if err := Watch(ctx, path, func() { calls.Add(1) }); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("x"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	time.Sleep(watchDebounce + 200*time.Millisecond)
+
+	if n := calls.Load(); n != 0 {
+		t.Errorf("expected no calls for unrelated files, got %d", n)
+	}
+}
+
+func waitForCalls(t *testing.T, calls *atomic.Int32, want int32) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for calls.Load() < want {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d calls, got %d", want, calls.Load())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// No extra calls from the same burst
+	time.Sleep(watchDebounce + 200*time.Millisecond)
+	if n := calls.Load(); n != want {
+		t.Fatalf("expected %d calls, got %d", want, n)
+	}
+}
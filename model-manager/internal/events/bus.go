@@ -0,0 +1,60 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// further events are dropped for it
+const subscriberBuffer = 64
+
+// Bus fans model status events out to subscribers. Publishing never blocks:
+// a subscriber that stops reading misses events instead of stalling switches.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[chan models.StatusEvent]struct{}
+}
+
+// NewBus creates an event bus with no subscribers
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[chan models.StatusEvent]struct{}),
+	}
+}
+
+// Publish sends an event to every subscriber that has room for it
+func (b *Bus) Publish(event models.StatusEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Slow subscriber; drop rather than block the publisher
+		}
+	}
+}
+
+// Subscribe returns a channel of events and a function that unsubscribes and
+// closes the channel
+func (b *Bus) Subscribe() (<-chan models.StatusEvent, func()) {
+	ch := make(chan models.StatusEvent, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
@@ -0,0 +1,67 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+func TestBus_PublishToSubscribers(t *testing.T) {
+	bus := NewBus()
+
+	first, cancelFirst := bus.Subscribe()
+	defer cancelFirst()
+	second, cancelSecond := bus.Subscribe()
+	defer cancelSecond()
+
+	event := models.StatusEvent{ModelID: "model-a", OldStatus: models.StatusSleeping, NewStatus: models.StatusActive, Cause: models.CauseSwitch}
+	bus.Publish(event)
+
+	for _, ch := range []<-chan models.StatusEvent{first, second} {
+		select {
+		case got := <-ch:
+			if got != event {
+				t.Errorf("expected %+v, got %+v", event, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
+
+func TestBus_UnsubscribeClosesChannel(t *testing.T) {
+	bus := NewBus()
+
+	ch, cancel := bus.Subscribe()
+	cancel()
+	cancel() // Safe to call twice
+
+	if _, ok := <-ch; ok {
+		t.Error("expected channel to be closed")
+	}
+
+	// Publishing after unsubscribe must not panic
+	bus.Publish(models.StatusEvent{ModelID: "model-a"})
+}
+
+func TestBus_SlowSubscriberDoesNotBlock(t *testing.T) {
+	bus := NewBus()
+
+	_, cancel := bus.Subscribe()
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriberBuffer*2; i++ {
+			bus.Publish(models.StatusEvent{ModelID: "model-a"})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a subscriber that isn't reading")
+	}
+}
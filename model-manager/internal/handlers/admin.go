@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zheng/homeGPT/internal/config"
+	"github.com/zheng/homeGPT/internal/switcher"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// CreateModel registers a new model (POST /admin/models/:id)
+func (h *Handler) CreateModel(c *gin.Context) {
+	h.putModel(c, true)
+}
+
+// UpdateModel replaces the settings of an existing model (PUT /admin/models/:id)
+func (h *Handler) UpdateModel(c *gin.Context) {
+	h.putModel(c, false)
+}
+
+// putModel creates or updates a model in the registry, validating the result
+// with the same rules as config.yaml
+func (h *Handler) putModel(c *gin.Context, create bool) {
+	id := c.Param("id")
+
+	var req models.ModelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	model, err := req.ToModel(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	persist, ok := h.persistRequested(c)
+	if !ok {
+		return
+	}
+
+	h.adminMu.Lock()
+	defer h.adminMu.Unlock()
+
+	cfg := h.switcher.Config()
+	i := modelIndex(cfg, id)
+	switch {
+	case create && i >= 0:
+		c.JSON(http.StatusConflict, gin.H{"error": "model already exists: " + id})
+		return
+	case !create && i < 0:
+		c.JSON(http.StatusNotFound, gin.H{"error": "model not found: " + id})
+		return
+	case i >= 0:
+		cfg.Models[i] = model.Snapshot()
+	default:
+		cfg.Models = append(cfg.Models, model.Snapshot())
+	}
+
+	if !h.applyConfig(c, cfg) {
+		return
+	}
+
+	action := "Updated"
+	status := http.StatusOK
+	if create {
+		action = "Registered"
+		status = http.StatusCreated
+	}
+	log.Printf("%s model %s through the admin API", action, id)
+
+	if persist {
+		if err := config.SaveModel(h.configPath, model); err != nil {
+			log.Printf("Failed to write model %s to %s: %v", id, h.configPath, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("model %s applied but not saved: %v", id, err)})
+			return
+		}
+	}
+
+	snapshot, _ := h.switcher.GetModel(id)
+	c.JSON(status, &snapshot)
+}
+
+// DeleteModel retires a model, putting it to sleep first if it is awake
+// (DELETE /admin/models/:id)
+func (h *Handler) DeleteModel(c *gin.Context) {
+	id := c.Param("id")
+
+	persist, ok := h.persistRequested(c)
+	if !ok {
+		return
+	}
+
+	h.adminMu.Lock()
+	defer h.adminMu.Unlock()
+
+	cfg := h.switcher.Config()
+	i := modelIndex(cfg, id)
+	if i < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "model not found: " + id})
+		return
+	}
+	remaining := make([]models.Model, 0, len(cfg.Models)-1)
+	for j := range cfg.Models {
+		if j != i {
+			remaining = append(remaining, cfg.Models[j].Snapshot())
+		}
+	}
+	cfg.Models = remaining
+
+	if !h.applyConfig(c, cfg) {
+		return
+	}
+	log.Printf("Removed model %s through the admin API", id)
+
+	if persist {
+		if err := config.DeleteModel(h.configPath, id); err != nil {
+			log.Printf("Failed to remove model %s from %s: %v", id, h.configPath, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("model %s removed but not saved: %v", id, err)})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "deleted",
+		"model_id": id,
+	})
+}
+
+// persistRequested reports whether the request asked for ?persist=true, and
+// responds 400 if it did but there is no config file to write to
+func (h *Handler) persistRequested(c *gin.Context) (bool, bool) {
+	if c.Query("persist") != "true" {
+		return false, true
+	}
+	if h.configPath == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "persist=true is not available: no config file configured"})
+		return false, false
+	}
+	return true, true
+}
+
+// applyConfig validates cfg and hands it to the switcher, responding with an
+// error and returning false if either rejects it
+func (h *Handler) applyConfig(c *gin.Context, cfg *models.Config) bool {
+	if err := config.Validate(cfg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	// Retiring a model may mean putting it to sleep; don't tie that to the client
+	if err := h.switcher.Reload(context.Background(), cfg); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, switcher.ErrConfigRejected) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// modelIndex returns the index of the model with the given ID in cfg, or -1
+func modelIndex(cfg *models.Config, id string) int {
+	for i := range cfg.Models {
+		if cfg.Models[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zheng/homeGPT/internal/config"
+	"github.com/zheng/homeGPT/internal/switcher"
+	"github.com/zheng/homeGPT/internal/system"
+	"github.com/zheng/homeGPT/internal/vllm"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+const adminTestConfig = `# Test configuration
+models:
+  - id: model-a
+    name: "Model A"
+    container_name: vllm-a
+    port: 8000
+    startup_mode: active  # The default model
+
+  - id: model-b
+    name: "Model B"
+    container_name: vllm-b
+    port: 8000
+    startup_mode: sleep
+`
+
+const adminTestToken = "secret"
+
+// setupAdminRouter serves the admin API for a switcher loaded from a temporary
+// config file, whose path is returned
+func setupAdminRouter(t *testing.T) (*gin.Engine, *Handler, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(adminTestConfig), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	// Every model other than model-a starts out asleep
+	mockClient := vllm.NewMockClient()
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		return host != "vllm-a", nil
+	}
+
+	s := switcher.NewWithClient(cfg, mockClient,
+		switcher.WithoutBackgroundTasks(),
+		switcher.WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 128}),
+		switcher.WithMaxRetries(2),
+		switcher.WithHealthCheckInterval(10*time.Millisecond))
+	h := New(s, WithConfigPath(path))
+
+	r := gin.New()
+	auth := NewAuthenticator([]models.APIKey{{Name: "test-admin", Hash: HashKey(adminTestToken), Role: models.RoleAdmin}})
+	admin := r.Group("/admin", auth.Require(models.RoleAdmin))
+	admin.POST("/models/:id", h.CreateModel)
+	admin.PUT("/models/:id", h.UpdateModel)
+	admin.DELETE("/models/:id", h.DeleteModel)
+	return r, h, path
+}
+
+func adminRequest(r *gin.Engine, method, url string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, url, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+adminTestToken)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdmin_RequiresToken(t *testing.T) {
+	r, _, _ := setupAdminRouter(t)
+
+	for _, auth := range []string{"", "Bearer wrong"} {
+		req := httptest.NewRequest("DELETE", "/admin/models/model-b", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401 for %q, got %d", auth, w.Code)
+		}
+	}
+}
+
+func TestAdmin_CreateModel(t *testing.T) {
+	r, h, path := setupAdminRouter(t)
+
+	body := models.ModelRequest{
+		Name:          "Model C",
+		ContainerName: "vllm-c",
+		Port:          8000,
+		GPUMemoryGB:   24,
+		StartupMode:   models.StartupSleep,
+		IdleTimeout:   "30m",
+	}
+	w := adminRequest(r, "POST", "/admin/models/model-c?persist=true", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var created struct {
+		ID          string             `json:"id"`
+		Status      models.ModelStatus `json:"status"`
+		IdleTimeout string             `json:"idle_timeout"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.ID != "model-c" || created.Status != models.StatusSleeping || created.IdleTimeout != "30m0s" {
+		t.Errorf("unexpected created model: %+v", created)
+	}
+
+	if _, ok := h.switcher.GetModel("model-c"); !ok {
+		t.Error("expected model-c to be registered")
+	}
+
+	// Written back to the file, which still loads
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("expected saved config to load, got %v", err)
+	}
+	if len(cfg.Models) != 3 || cfg.Models[2].ID != "model-c" || cfg.Models[2].IdleTimeout != 30*time.Minute {
+		t.Errorf("expected model-c saved to config file, got %+v", cfg.Models)
+	}
+
+	// Creating it again conflicts
+	w = adminRequest(r, "POST", "/admin/models/model-c", body)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for duplicate model, got %d", w.Code)
+	}
+}
+
+func TestAdmin_CreateModel_Invalid(t *testing.T) {
+	r, h, _ := setupAdminRouter(t)
+
+	tests := []struct {
+		name string
+		body models.ModelRequest
+	}{
+		{"missing container", models.ModelRequest{Port: 8000, StartupMode: models.StartupSleep}},
+		{"bad startup mode", models.ModelRequest{ContainerName: "vllm-c", Port: 8000, StartupMode: "awake"}},
+		{"second active model", models.ModelRequest{ContainerName: "vllm-c", Port: 8000, StartupMode: models.StartupActive}},
+		{"bad idle timeout", models.ModelRequest{ContainerName: "vllm-c", Port: 8000, StartupMode: models.StartupSleep, IdleTimeout: "soon"}},
+		{"missing port", models.ModelRequest{ContainerName: "vllm-c", StartupMode: models.StartupSleep}},
+		{"negative port", models.ModelRequest{ContainerName: "vllm-c", Port: -1, StartupMode: models.StartupSleep}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := adminRequest(r, "POST", "/admin/models/model-c", tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if _, ok := h.switcher.GetModel("model-c"); ok {
+		t.Error("expected invalid models not to be registered")
+	}
+}
+
+func TestAdmin_UpdateModel(t *testing.T) {
+	r, h, path := setupAdminRouter(t)
+
+	body := models.ModelRequest{
+		Name:          "Model A (tuned)",
+		ContainerName: "vllm-a",
+		Port:          8000,
+		GPUMemoryGB:   40,
+		StartupMode:   models.StartupActive,
+	}
+	w := adminRequest(r, "PUT", "/admin/models/model-a?persist=true", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	model, _ := h.switcher.GetModel("model-a")
+	if model.GPUMemoryGB != 40 || model.GetStatus() != models.StatusActive {
+		t.Errorf("expected model-a to stay active with new settings, got %.0f GB, %s", model.GPUMemoryGB, model.GetStatus())
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "# The default model") || !strings.Contains(string(data), "Model A (tuned)") {
+		t.Errorf("expected update saved with comments kept, got:\n%s", data)
+	}
+
+	w = adminRequest(r, "PUT", "/admin/models/unknown", body)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for unknown model, got %d", w.Code)
+	}
+}
+
+func TestAdmin_DeleteModel(t *testing.T) {
+	r, h, path := setupAdminRouter(t)
+
+	w := adminRequest(r, "DELETE", "/admin/models/model-b?persist=true", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := h.switcher.GetModel("model-b"); ok {
+		t.Error("expected model-b to be removed")
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("expected saved config to load, got %v", err)
+	}
+	if len(cfg.Models) != 1 || cfg.Models[0].ID != "model-a" {
+		t.Errorf("expected only model-a left in config file, got %+v", cfg.Models)
+	}
+
+	// The only model starting active can't be removed
+	w = adminRequest(r, "DELETE", "/admin/models/model-a", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 removing the last model, got %d", w.Code)
+	}
+}
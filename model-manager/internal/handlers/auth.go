@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zheng/homeGPT/internal/switcher"
+	"github.com/zheng/homeGPT/pkg/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Context keys for the API key a request authenticated with
+const (
+	keyNameContextKey     = "auth.key_name"
+	keyRoleContextKey     = "auth.key_role"
+	keyPriorityContextKey = "auth.key_priority"
+)
+
+// accessTokenParam is the query parameter event stream clients send their key in
+const accessTokenParam = "access_token"
+
+// HashKey returns the hash of an API key as it is stored in the config
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// GenerateKey returns a new random API key
+func GenerateKey() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return "hgpt_" + hex.EncodeToString(b)
+}
+
+// KeyName returns the name of the API key the request authenticated with, or ""
+func KeyName(c *gin.Context) string {
+	return c.GetString(keyNameContextKey)
+}
+
+// keyRole returns the role of the API key the request authenticated with, or ""
+func keyRole(c *gin.Context) models.Role {
+	role, _ := c.Get(keyRoleContextKey)
+	r, _ := role.(models.Role)
+	return r
+}
+
+// requestContext returns the request's context, attributing switches started
+// under it to the request's API key, or to "anonymous" while auth is off, and
+// giving them and inference requests the key's priority
+func requestContext(c *gin.Context) context.Context {
+	actor := KeyName(c)
+	if actor == "" {
+		actor = "anonymous"
+	}
+	ctx := switcher.WithActor(c.Request.Context(), actor)
+	return switcher.WithPriority(ctx, c.GetInt(keyPriorityContextKey))
+}
+
+// Authenticator checks bearer API keys against the configured key hashes. Its
+// keys can be replaced at runtime when the config is reloaded.
+type Authenticator struct {
+	mu   sync.RWMutex
+	keys map[string]models.APIKey // By hash
+}
+
+// NewAuthenticator creates an authenticator accepting the given keys
+func NewAuthenticator(keys []models.APIKey) *Authenticator {
+	a := &Authenticator{}
+	a.SetKeys(keys)
+	return a
+}
+
+// SetKeys replaces the accepted keys
+func (a *Authenticator) SetKeys(keys []models.APIKey) {
+	byHash := make(map[string]models.APIKey, len(keys))
+	for _, key := range keys {
+		byHash[key.Hash] = key
+	}
+
+	a.mu.Lock()
+	a.keys = byHash
+	a.mu.Unlock()
+}
+
+// Enabled reports whether any keys are configured
+func (a *Authenticator) Enabled() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.keys) > 0
+}
+
+// Require rejects requests without an API key whose role includes role, sent as
+// "Authorization: Bearer <key>". While no keys are configured, requests are let
+// through except to admin routes. Authenticated requests that change state are
+// logged with the key's name.
+func (a *Authenticator) Require(role models.Role) gin.HandlerFunc {
+	return a.require(role, false)
+}
+
+// RequireStream is Require for the event stream routes, whose browser
+// EventSource and WebSocket clients can't set headers. They may send the key
+// as an access_token query parameter instead.
+func (a *Authenticator) RequireStream(role models.Role) gin.HandlerFunc {
+	return a.require(role, true)
+}
+
+// require builds the Require middleware, taking the key from the access_token
+// query parameter too if allowQuery is set
+func (a *Authenticator) require(role models.Role, allowQuery bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.Enabled() && role != models.RoleAdmin {
+			c.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok && allowQuery {
+			token = c.Query(accessTokenParam)
+		}
+
+		a.mu.RLock()
+		key, found := a.keys[HashKey(token)]
+		a.mu.RUnlock()
+
+		if token == "" || !found {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid API key"})
+			return
+		}
+		if !key.Role.Includes(role) {
+			log.Printf("Denied %s %s to key %s (%s, %s required)", c.Request.Method, c.Request.URL.Path, key.Name, key.Role, role)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "API key " + key.Name + " has role " + string(key.Role) + ", " + string(role) + " required",
+			})
+			return
+		}
+
+		c.Set(keyNameContextKey, key.Name)
+		c.Set(keyRoleContextKey, key.Role)
+		c.Set(keyPriorityContextKey, key.Priority)
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(
+			attribute.String("enduser.id", key.Name),
+			attribute.String("enduser.role", string(key.Role)))
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			log.Printf("%s %s by key %s (%s)", c.Request.Method, c.Request.URL.Path, key.Name, key.Role)
+		}
+		c.Next()
+	}
+}
+
+// RequestLogger logs each request like gin's default logger, with the key in
+// an access_token query parameter redacted so it never reaches the logs
+func RequestLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(p gin.LogFormatterParams) string {
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+			p.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			p.StatusCode, p.Latency, p.ClientIP, p.Method, redactToken(p.Path), p.ErrorMessage)
+	})
+}
+
+// redactToken replaces the value of an access_token query parameter in a
+// request path with REDACTED
+func redactToken(path string) string {
+	base, rawQuery, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// Don't risk logging a key in a query that can't be parsed
+		return base + "?REDACTED"
+	}
+	if !query.Has(accessTokenParam) {
+		return path
+	}
+	query.Set(accessTokenParam, "REDACTED")
+	return base + "?" + query.Encode()
+}
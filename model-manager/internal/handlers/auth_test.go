@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+func setupAuthRouter(auth *Authenticator) *gin.Engine {
+	r := gin.New()
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"key": KeyName(c)}) }
+	r.GET("/models", auth.Require(models.RoleViewer), ok)
+	r.POST("/switch", auth.Require(models.RoleOperator), ok)
+	r.DELETE("/admin/models/:id", auth.Require(models.RoleAdmin), ok)
+	r.GET("/events", auth.RequireStream(models.RoleViewer), ok)
+	return r
+}
+
+func authRequest(r *gin.Engine, method, url, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, nil)
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func testAuthenticator() *Authenticator {
+	return NewAuthenticator([]models.APIKey{
+		{Name: "dashboard", Hash: HashKey("viewer-key"), Role: models.RoleViewer},
+		{Name: "open-webui", Hash: HashKey("operator-key"), Role: models.RoleOperator},
+		{Name: "ops", Hash: HashKey("admin-key"), Role: models.RoleAdmin},
+	})
+}
+
+func TestAuth_RolesGrantRoutes(t *testing.T) {
+	r := setupAuthRouter(testAuthenticator())
+
+	tests := []struct {
+		key    string
+		method string
+		url    string
+		want   int
+	}{
+		{"viewer-key", "GET", "/models", http.StatusOK},
+		{"viewer-key", "POST", "/switch", http.StatusForbidden},
+		{"operator-key", "GET", "/models", http.StatusOK},
+		{"operator-key", "POST", "/switch", http.StatusOK},
+		{"operator-key", "DELETE", "/admin/models/model-b", http.StatusForbidden},
+		{"admin-key", "POST", "/switch", http.StatusOK},
+		{"admin-key", "DELETE", "/admin/models/model-b", http.StatusOK},
+		{"", "GET", "/models", http.StatusUnauthorized},
+		{"wrong-key", "GET", "/models", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		w := authRequest(r, tt.method, tt.url, tt.key)
+		if w.Code != tt.want {
+			t.Errorf("%s %s with key %q: expected status %d, got %d", tt.method, tt.url, tt.key, tt.want, w.Code)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("%s %s with key %q: expected a WWW-Authenticate challenge", tt.method, tt.url, tt.key)
+		}
+	}
+}
+
+func TestAuth_RecordsKeyName(t *testing.T) {
+	r := setupAuthRouter(testAuthenticator())
+
+	w := authRequest(r, "POST", "/switch", "operator-key")
+	if w.Body.String() != `{"key":"open-webui"}` {
+		t.Errorf("expected the request to carry key name open-webui, got %s", w.Body.String())
+	}
+}
+
+func TestAuth_AccessTokenQueryOnlyOnStreams(t *testing.T) {
+	r := setupAuthRouter(testAuthenticator())
+
+	w := authRequest(r, "GET", "/events?access_token=viewer-key", "")
+	if w.Code != http.StatusOK {
+		t.Errorf("expected access_token query parameter to authenticate an event stream, got %d", w.Code)
+	}
+
+	w = authRequest(r, "GET", "/models?access_token=viewer-key", "")
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected access_token query parameter to be ignored on /models, got %d", w.Code)
+	}
+	w = authRequest(r, "POST", "/switch?access_token=operator-key", "")
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected access_token query parameter to be ignored on /switch, got %d", w.Code)
+	}
+}
+
+func TestRequestLogger_RedactsAccessToken(t *testing.T) {
+	var logs bytes.Buffer
+	gin.DefaultWriter = &logs
+	defer func() { gin.DefaultWriter = os.Stdout }()
+
+	r := gin.New()
+	r.Use(RequestLogger())
+	r.GET("/events", func(c *gin.Context) { c.Status(http.StatusOK) })
+	authRequest(r, "GET", "/events?access_token=viewer-key&since=5", "")
+
+	if strings.Contains(logs.String(), "viewer-key") {
+		t.Errorf("expected the key to be redacted, got %q", logs.String())
+	}
+	if !strings.Contains(logs.String(), "/events?access_token=REDACTED&since=5") {
+		t.Errorf("expected the redacted path to be logged, got %q", logs.String())
+	}
+}
+
+func TestAuth_OpenWithoutKeysExceptAdmin(t *testing.T) {
+	auth := NewAuthenticator(nil)
+	r := setupAuthRouter(auth)
+
+	if w := authRequest(r, "POST", "/switch", ""); w.Code != http.StatusOK {
+		t.Errorf("expected switch to be open without keys, got %d", w.Code)
+	}
+	if w := authRequest(r, "DELETE", "/admin/models/model-b", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected admin routes to stay closed without keys, got %d", w.Code)
+	}
+
+	// Keys added by a config reload apply to the next request
+	auth.SetKeys([]models.APIKey{{Name: "ops", Hash: HashKey("admin-key"), Role: models.RoleAdmin}})
+	if w := authRequest(r, "POST", "/switch", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected switch to require a key once keys are configured, got %d", w.Code)
+	}
+	if w := authRequest(r, "DELETE", "/admin/models/model-b", "admin-key"); w.Code != http.StatusOK {
+		t.Errorf("expected the new admin key to be accepted, got %d", w.Code)
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORS lets browser pages from the allowed origins call the API. "*" allows any
+// origin; with none allowed, no CORS headers are sent and browsers only permit
+// same-origin requests.
+func CORS(allowedOrigins []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Origin")
+		origin := c.GetHeader("Origin")
+		if origin != "" && originAllowed(allowedOrigins, origin) {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		}
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// originAllowed reports whether origin is one of the allowed origins, or any
+// origin is allowed with "*"
+func originAllowed(allowedOrigins []string, origin string) bool {
+	return slices.Contains(allowedOrigins, "*") || slices.Contains(allowedOrigins, origin)
+}
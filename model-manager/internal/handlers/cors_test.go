@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupCORSRouter(allowedOrigins []string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CORS(allowedOrigins))
+	r.GET("/models", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func corsRequest(r *gin.Engine, method, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/models", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORS_AllowedOrigin(t *testing.T) {
+	r := setupCORSRouter([]string{"https://dashboard.home"})
+
+	w := corsRequest(r, http.MethodGet, "https://dashboard.home")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://dashboard.home" {
+		t.Errorf("expected the origin to be allowed, got %q", got)
+	}
+
+	w = corsRequest(r, http.MethodGet, "https://evil.example")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no CORS header for another origin, got %q", got)
+	}
+}
+
+func TestCORS_NoOriginsConfigured(t *testing.T) {
+	r := setupCORSRouter(nil)
+
+	w := corsRequest(r, http.MethodGet, "https://dashboard.home")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no CORS header, got %q", got)
+	}
+}
+
+func TestCORS_AnyOrigin(t *testing.T) {
+	r := setupCORSRouter([]string{"*"})
+
+	w := corsRequest(r, http.MethodOptions, "https://dashboard.home")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected a preflight to get 204, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://dashboard.home" {
+		t.Errorf("expected any origin to be allowed, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, Authorization" {
+		t.Errorf("expected the Authorization header to be allowed, got %q", got)
+	}
+}
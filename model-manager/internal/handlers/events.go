@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// eventKeepAlive is how often an idle event stream sends a keep-alive so
+// proxies don't close it
+const eventKeepAlive = 30 * time.Second
+
+// Events streams model status changes as Server-Sent Events
+func (h *Handler) Events(c *gin.Context) {
+	events, unsubscribe := h.switcher.Events().Subscribe()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			c.SSEvent("status", event)
+			c.Writer.Flush()
+		case <-keepAlive.C:
+			c.Writer.WriteString(": keep-alive\n\n")
+			c.Writer.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// WebSocket streams model status changes as JSON messages over a WebSocket
+func (h *Handler) WebSocket(c *gin.Context) {
+	upgrader := websocket.Upgrader{CheckOrigin: h.checkOrigin}
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	events, unsubscribe := h.switcher.Events().Subscribe()
+	defer unsubscribe()
+
+	// Read (and discard) client messages so close frames and disconnects are noticed
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			deadline := time.Now().Add(10 * time.Second)
+			if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// checkOrigin accepts WebSocket connections from clients that send no Origin
+// (anything but a browser), from pages on our own host, and from the origins
+// the CORS policy allows. Without it any page a visitor opens could follow the
+// events while no API keys are configured.
+func (h *Handler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return originAllowed(h.origins, origin)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+func newEventsServer(t *testing.T, opts ...Option) (*Handler, *httptest.Server) {
+	t.Helper()
+
+	h, _ := setupTestHandler()
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	router := gin.New()
+	router.GET("/events", h.Events)
+	router.GET("/ws", h.WebSocket)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return h, server
+}
+
+func TestEvents_SSE(t *testing.T) {
+	h, server := newEventsServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("expected text/event-stream, got %s", ct)
+	}
+
+	go h.switcher.SwitchModel(context.Background(), "model-b")
+
+	reader := bufio.NewReader(resp.Body)
+	var eventName string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read event stream: %v", err)
+		}
+		line = strings.TrimSpace(line)
+
+		if name, ok := strings.CutPrefix(line, "event:"); ok {
+			eventName = name
+			continue
+		}
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+
+		if eventName != "status" {
+			t.Errorf("expected status event, got %q", eventName)
+		}
+
+		var event models.StatusEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("failed to unmarshal event %q: %v", data, err)
+		}
+		if event.ModelID != "model-a" || event.NewStatus != models.StatusSwitching || event.Cause != models.CauseSwitch {
+			t.Errorf("unexpected first event: %+v", event)
+		}
+		return
+	}
+}
+
+func TestEvents_WebSocket(t *testing.T) {
+	h, server := newEventsServer(t)
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial websocket: %v", err)
+	}
+	defer conn.Close()
+
+	// Give the handler a moment to subscribe before the switch starts
+	time.Sleep(20 * time.Millisecond)
+	go h.switcher.SwitchModel(context.Background(), "model-b")
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var last models.StatusEvent
+	for last.ModelID != "model-b" || last.NewStatus != models.StatusActive {
+		if err := conn.ReadJSON(&last); err != nil {
+			t.Fatalf("failed to read event: %v", err)
+		}
+	}
+
+	if last.OldStatus != models.StatusSwitching || last.Timestamp.IsZero() {
+		t.Errorf("unexpected final event: %+v", last)
+	}
+}
+
+func TestEvents_WebSocketChecksOrigin(t *testing.T) {
+	_, server := newEventsServer(t, WithAllowedOrigins([]string{"https://dashboard.home"}))
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{server.URL, true},
+		{"https://dashboard.home", true},
+		{"https://evil.example", false},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.origin != "" {
+			header.Set("Origin", tt.origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+		if conn != nil {
+			conn.Close()
+		}
+		if tt.want && err != nil {
+			t.Errorf("origin %q: expected the connection to be accepted, got %v", tt.origin, err)
+		}
+		if !tt.want && (err == nil || resp == nil || resp.StatusCode != http.StatusForbidden) {
+			t.Errorf("origin %q: expected the connection to be refused with 403, got %v", tt.origin, err)
+		}
+	}
+}
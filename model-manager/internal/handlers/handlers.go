@@ -1,24 +1,61 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/zheng/homeGPT/internal/audit"
 	"github.com/zheng/homeGPT/internal/switcher"
+	"github.com/zheng/homeGPT/internal/vllm"
 	"github.com/zheng/homeGPT/pkg/models"
 )
 
 // Handler handles HTTP requests for model switching
 type Handler struct {
-	switcher *switcher.Switcher
+	switcher   *switcher.Switcher
+	transport  http.RoundTripper // Streaming transport used to relay inference requests to vLLM
+	configPath string            // Config file admin changes are written back to (empty = never)
+	origins    []string          // Browser origins besides our own allowed to open a WebSocket
+	adminMu    sync.Mutex        // Serializes admin changes to the model registry
+}
+
+// Option is a function that configures the Handler
+type Option func(*Handler)
+
+// WithConfigPath lets admin requests with ?persist=true write their changes
+// back to the config file at path
+func WithConfigPath(path string) Option {
+	return func(h *Handler) {
+		h.configPath = path
+	}
+}
+
+// WithAllowedOrigins lets browser pages from the given origins open the event
+// WebSocket, matching the CORS policy ("*" allows any origin)
+func WithAllowedOrigins(origins []string) Option {
+	return func(h *Handler) {
+		h.origins = origins
+	}
 }
 
 // New creates a new HTTP handler
-func New(s *switcher.Switcher) *Handler {
-	return &Handler{
-		switcher: s,
+func New(s *switcher.Switcher, opts ...Option) *Handler {
+	h := &Handler{
+		switcher:  s,
+		transport: vllm.NewStreamTransport(vllm.DefaultStreamIdleTimeout),
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 // Health returns the service health status
@@ -44,9 +81,49 @@ func (h *Handler) SwitchModel(c *gin.Context) {
 
 	log.Printf("Received switch request to model: %s", req.ModelID)
 
-	if err := h.switcher.SwitchModel(c.Request.Context(), req.ModelID); err != nil {
+	ctx, err := switchContext(c, req)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Async || c.Query("async") == "true" {
+		h.startSwitchJob(c, ctx, req.ModelID)
+		return
+	}
+
+	if err := h.switcher.SwitchModel(ctx, req.ModelID); err != nil {
 		log.Printf("Switch failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		// A vLLM server behind an open circuit breaker is down, not misbehaving
+		var circuitErr *vllm.CircuitOpenError
+		var preemptErr *switcher.PreemptionError
+		var cooldownErr *switcher.CooldownError
+		switch {
+		case errors.As(err, &preemptErr):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":         err.Error(),
+				"busy_model":    preemptErr.ModelID,
+				"busy_priority": preemptErr.Priority,
+			})
+			return
+		case errors.As(err, &cooldownErr):
+			retryAfter := int(math.Ceil(cooldownErr.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusConflict, gin.H{
+				"error":               err.Error(),
+				"reason":              cooldownErr.Reason,
+				"model_id":            cooldownErr.ModelID,
+				"retry_after_seconds": retryAfter,
+			})
+			return
+		case errors.As(err, &circuitErr):
+			status = http.StatusServiceUnavailable
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(circuitErr.RetryAfter.Seconds()))))
+		case errors.Is(err, switcher.ErrSwitchCanceled):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -55,3 +132,161 @@ func (h *Handler) SwitchModel(c *gin.Context) {
 		"active_model": req.ModelID,
 	})
 }
+
+// switchContext returns the context to run a switch request under, with the
+// request's priority and force flag. A request may lower its API key's priority
+// but not raise it, and only admin keys may force a switch.
+func switchContext(c *gin.Context, req models.SwitchRequest) (context.Context, error) {
+	ctx := requestContext(c)
+	isAdmin := keyRole(c) == models.RoleAdmin
+
+	if req.Priority != nil {
+		// While auth is off there is no key priority to hold requests to
+		if limit := c.GetInt(keyPriorityContextKey); keyRole(c) != "" && !isAdmin && *req.Priority > limit {
+			return nil, fmt.Errorf("priority %d is above the API key's priority %d", *req.Priority, limit)
+		}
+		ctx = switcher.WithPriority(ctx, *req.Priority)
+	}
+	if req.Force {
+		if !isAdmin {
+			return nil, errors.New("force requires an admin API key")
+		}
+		log.Printf("Switch to %s forced by key %s", req.ModelID, KeyName(c))
+		ctx = switcher.WithForce(ctx)
+	}
+	return ctx, nil
+}
+
+// startSwitchJob starts a switch in the background and responds 202 with its job ID
+func (h *Handler) startSwitchJob(c *gin.Context, ctx context.Context, modelID string) {
+	job, err := h.switcher.StartSwitchJob(ctx, modelID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, switcher.ErrModelNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	statusURL := "/switch/jobs/" + job.ID
+	c.Header("Location", statusURL)
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id":     job.ID,
+		"status_url": statusURL,
+		"job":        job,
+	})
+}
+
+// GetSwitchJob reports the progress of an asynchronous switch
+func (h *Handler) GetSwitchJob(c *gin.Context) {
+	job, ok := h.switcher.GetSwitchJob(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "switch job not found: " + c.Param("id")})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelSwitch aborts the switch in progress. The switch rolls back in the
+// background: the target goes back to sleep and the evicted models are woken.
+func (h *Handler) CancelSwitch(c *gin.Context) {
+	modelID, err := h.switcher.CancelSwitch()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":   "canceling",
+		"model_id": modelID,
+	})
+}
+
+// CancelSwitchJob aborts an asynchronous switch, running or still queued
+func (h *Handler) CancelSwitchJob(c *gin.Context) {
+	job, err := h.switcher.CancelSwitchJob(c.Param("id"))
+	switch {
+	case errors.Is(err, switcher.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, switcher.ErrJobFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "job": job})
+	default:
+		c.JSON(http.StatusAccepted, job)
+	}
+}
+
+// GetSwitchHistory returns completed switches, newest first, optionally limited
+// by ?limit=N
+func (h *Handler) GetSwitchHistory(c *gin.Context) {
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit: " + raw})
+			return
+		}
+		limit = n
+	}
+
+	history, err := h.switcher.SwitchHistory(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": history})
+}
+
+// defaultAuditLimit caps the audit entries returned when no limit is given
+const defaultAuditLimit = 100
+
+// GetAudit returns audit log entries, newest first, filtered by ?model=ID,
+// ?since= (an RFC 3339 time or a duration back from now, like 24h) and ?limit=N
+func (h *Handler) GetAudit(c *gin.Context) {
+	filter := audit.Filter{ModelID: c.Query("model"), Limit: defaultAuditLimit}
+
+	if raw := c.Query("since"); raw != "" {
+		if since, err := time.Parse(time.RFC3339, raw); err == nil {
+			filter.Since = since
+		} else if d, err := time.ParseDuration(raw); err == nil && d > 0 {
+			filter.Since = time.Now().Add(-d)
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since (want an RFC 3339 time or a duration): " + raw})
+			return
+		}
+	}
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit: " + raw})
+			return
+		}
+		filter.Limit = n
+	}
+
+	entries, err := h.switcher.AuditLog(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// GetSchedules returns the configured schedules, the slot in effect, any manual
+// override and the next ?limit=N firings (default 10)
+func (h *Handler) GetSchedules(c *gin.Context) {
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit: " + raw})
+			return
+		}
+		limit = n
+	}
+
+	c.JSON(http.StatusOK, h.switcher.Schedules(limit))
+}
@@ -7,11 +7,15 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/zheng/homeGPT/internal/audit"
 	"github.com/zheng/homeGPT/internal/switcher"
+	"github.com/zheng/homeGPT/internal/system"
 	"github.com/zheng/homeGPT/internal/vllm"
 	"github.com/zheng/homeGPT/pkg/models"
 )
@@ -317,6 +321,62 @@ func TestSwitchModel_SwitchFailure(t *testing.T) {
 	}
 }
 
+func TestSwitchModel_CircuitOpen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &models.Config{
+		CircuitBreaker: models.BreakerConfig{FailureThreshold: 1, OpenSeconds: 60},
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8001, StartupMode: models.StartupSleep},
+		},
+	}
+
+	mockClient := vllm.NewMockClient()
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		if host == "vllm-b" {
+			return true, nil
+		}
+		for _, call := range mockClient.SleepCalls {
+			if call.Host == host {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	// model-b's container is gone
+	mockClient.WakeUpFunc = func(ctx context.Context, host string, port int) error {
+		if host == "vllm-b" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	s := switcher.NewWithClient(cfg, mockClient, switcher.WithCircuitBreaker(),
+		switcher.WithMaxRetries(2), switcher.WithHealthCheckInterval(10*time.Millisecond))
+	s.WaitForInit()
+
+	h := New(s)
+
+	// The first failure opens the circuit, the second switch fails fast
+	for i, want := range []int{http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		bodyBytes, _ := json.Marshal(models.SwitchRequest{ModelID: "model-b"})
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/switch", bytes.NewBuffer(bodyBytes))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		h.SwitchModel(c)
+
+		if w.Code != want {
+			t.Errorf("switch %d: expected status %d, got %d", i+1, want, w.Code)
+		}
+		if want == http.StatusServiceUnavailable && w.Header().Get("Retry-After") == "" {
+			t.Error("expected a Retry-After header")
+		}
+	}
+}
+
 func TestSwitchModel_AlreadyActive(t *testing.T) {
 	h, mockClient := setupTestHandler()
 
@@ -347,3 +407,408 @@ func TestSwitchModel_AlreadyActive(t *testing.T) {
 		t.Errorf("expected 0 wake_up calls, got %d", len(mockClient.WakeUpCalls))
 	}
 }
+
+func TestSwitchModel_Async(t *testing.T) {
+	h, _ := setupTestHandler()
+
+	router := gin.New()
+	router.POST("/switch", h.SwitchModel)
+	router.GET("/switch/jobs/:id", h.GetSwitchJob)
+
+	bodyBytes, _ := json.Marshal(models.SwitchRequest{ModelID: "model-b", Async: true})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/switch", bytes.NewBuffer(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", w.Code)
+	}
+
+	var accepted struct {
+		JobID     string `json:"job_id"`
+		StatusURL string `json:"status_url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &accepted); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if accepted.JobID == "" || w.Header().Get("Location") != accepted.StatusURL {
+		t.Fatalf("expected job ID and matching Location header, got %+v / %s", accepted, w.Header().Get("Location"))
+	}
+
+	// Poll until the job finishes
+	var job models.SwitchJob
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Phase != models.PhaseDone {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for job, last phase %s", job.Phase)
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", accepted.StatusURL, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200 polling job, got %d", w.Code)
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatalf("failed to unmarshal job: %v", err)
+		}
+		if job.Phase == models.PhaseFailed {
+			t.Fatalf("expected job to succeed, got error %s", job.Error)
+		}
+	}
+
+	if job.ModelID != "model-b" || job.FinishedAt == nil {
+		t.Errorf("unexpected finished job: %+v", job)
+	}
+}
+
+func TestSwitchModel_AsyncNonexistentModel(t *testing.T) {
+	h, _ := setupTestHandler()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	req := httptest.NewRequest("POST", "/switch?async=true", bytes.NewBufferString(`{"model_id":"nonexistent"}`))
+	req.Header.Set("Content-Type", "application/json")
+	c.Request = req
+
+	h.SwitchModel(c)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestGetSwitchHistory(t *testing.T) {
+	h, _ := setupTestHandler()
+	h.switcher.WaitForInit()
+
+	for _, id := range []string{"model-b", "model-a"} {
+		if err := h.switcher.SwitchModel(context.Background(), id); err != nil {
+			t.Fatalf("expected no error switching to %s, got %v", id, err)
+		}
+	}
+
+	router := gin.New()
+	router.GET("/switch/history", h.GetSwitchHistory)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/switch/history?limit=1", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp struct {
+		History []models.SwitchRecord `json:"history"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(resp.History) != 1 || resp.History[0].ModelID != "model-a" || !resp.History[0].Success {
+		t.Errorf("expected only the latest switch to model-a, got %+v", resp.History)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/switch/history?limit=abc", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid limit, got %d", w.Code)
+	}
+}
+
+// setupStatefulSwitcher returns a switcher with model-a active and model-b
+// asleep, whose mock vLLM servers track sleep and wake calls
+func setupStatefulSwitcher(opts ...switcher.Option) *switcher.Switcher {
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupSleep},
+		},
+	}
+
+	var mu sync.Mutex
+	sleeping := map[string]bool{"vllm-b": true}
+	mockClient := vllm.NewMockClient()
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return sleeping[host], nil
+	}
+	mockClient.SleepFunc = func(ctx context.Context, host string, port int, level int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sleeping[host] = true
+		return nil
+	}
+	mockClient.WakeUpFunc = func(ctx context.Context, host string, port int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sleeping[host] = false
+		return nil
+	}
+
+	return switcher.NewWithClient(cfg, mockClient, append([]switcher.Option{
+		switcher.WithoutBackgroundTasks(),
+		switcher.WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 128}),
+		switcher.WithMaxRetries(2),
+		switcher.WithHealthCheckInterval(10 * time.Millisecond),
+	}, opts...)...)
+}
+
+func TestGetAudit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	l, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"), 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer l.Close()
+
+	s := setupStatefulSwitcher(switcher.WithAuditLog(l))
+	h := New(s)
+
+	auth := NewAuthenticator([]models.APIKey{{Name: "open-webui", Hash: HashKey("operator-key"), Role: models.RoleOperator}})
+	router := gin.New()
+	router.POST("/switch", auth.Require(models.RoleOperator), h.SwitchModel)
+	router.GET("/audit", auth.Require(models.RoleViewer), h.GetAudit)
+
+	for _, id := range []string{"model-b", "model-a"} {
+		req := httptest.NewRequest("POST", "/switch", bytes.NewBufferString(`{"model_id":"`+id+`"}`))
+		req.Header.Set("Authorization", "Bearer operator-key")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected switch to %s to succeed, got %d: %s", id, w.Code, w.Body.String())
+		}
+	}
+
+	get := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", "Bearer operator-key")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/audit?model=model-b&since=1h&limit=1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp struct {
+		Entries []models.AuditEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	// The newest entry involving model-b is the switch away from it
+	if len(resp.Entries) != 1 || resp.Entries[0].ModelID != "model-a" || resp.Entries[0].Actor != "open-webui" {
+		t.Errorf("expected the switch back to model-a by open-webui, got %+v", resp.Entries)
+	}
+
+	w = get("/audit?since=" + time.Now().Add(time.Hour).Format(time.RFC3339))
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || len(resp.Entries) != 0 {
+		t.Errorf("expected no entries since a future time, got %d %+v", w.Code, resp.Entries)
+	}
+
+	for _, url := range []string{"/audit?since=yesterday", "/audit?limit=-1"} {
+		if w := get(url); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", url, w.Code)
+		}
+	}
+}
+
+func TestSwitchModel_PriorityAndForce(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	s := setupStatefulSwitcher()
+	h := New(s)
+
+	auth := NewAuthenticator([]models.APIKey{
+		{Name: "kids", Hash: HashKey("kids-key"), Role: models.RoleOperator, Priority: 1},
+		{Name: "work", Hash: HashKey("work-key"), Role: models.RoleOperator, Priority: 10},
+		{Name: "ops", Hash: HashKey("admin-key"), Role: models.RoleAdmin},
+	})
+	router := gin.New()
+	router.POST("/switch", auth.Require(models.RoleOperator), h.SwitchModel)
+
+	switchTo := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/switch", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// work-priority generation is running on model-a
+	done := s.TrackRequest(switcher.WithPriority(context.Background(), 10), "model-a")
+	defer done()
+
+	w := switchTo("kids-key", `{"model_id":"model-b"}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status 409 for a lower-priority switch, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		BusyModel    string `json:"busy_model"`
+		BusyPriority int    `json:"busy_priority"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.BusyModel != "model-a" || resp.BusyPriority != 10 {
+		t.Errorf("expected model-a busy at priority 10, got %+v", resp)
+	}
+
+	// Keys can't claim more than their own priority, or force
+	if w := switchTo("kids-key", `{"model_id":"model-b","priority":10}`); w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a priority above the key's, got %d", w.Code)
+	}
+	if w := switchTo("work-key", `{"model_id":"model-b","force":true}`); w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for force without an admin key, got %d", w.Code)
+	}
+
+	if w := switchTo("admin-key", `{"model_id":"model-b","force":true}`); w.Code != http.StatusOK {
+		t.Errorf("expected a forced admin switch to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSwitchModel_Cooldown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	s := setupStatefulSwitcher()
+	h := New(s)
+
+	cfg := s.Config()
+	cfg.Models[1].MinResidency = time.Hour
+	if err := s.Reload(context.Background(), cfg); err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("failed to switch to model-b: %v", err)
+	}
+
+	router := gin.New()
+	router.POST("/switch", h.SwitchModel)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/switch", bytes.NewBufferString(`{"model_id":"model-a"}`)))
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status 409 within min_residency, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") != "3600" {
+		t.Errorf("expected Retry-After 3600, got %q", w.Header().Get("Retry-After"))
+	}
+	var resp struct {
+		Reason            string `json:"reason"`
+		ModelID           string `json:"model_id"`
+		RetryAfterSeconds int    `json:"retry_after_seconds"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Reason != switcher.CooldownMinResidency || resp.ModelID != "model-b" || resp.RetryAfterSeconds != 3600 {
+		t.Errorf("expected model-b held for 3600s by min_residency, got %+v", resp)
+	}
+}
+
+func TestGetSchedules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	s := setupStatefulSwitcher()
+	cfg := s.Config()
+	cfg.Schedules = []models.Schedule{
+		{Name: "morning", Cron: "0 8 * * *", Timezone: "UTC", ModelID: "model-a"},
+		{Name: "night", Cron: "0 23 * * *", Timezone: "UTC", Action: models.ScheduleSleep},
+	}
+	if err := s.Reload(context.Background(), cfg); err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+	h := New(s)
+
+	router := gin.New()
+	router.GET("/schedules", h.GetSchedules)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/schedules?limit=3", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp models.SchedulesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(resp.Schedules) != 2 || len(resp.Upcoming) != 3 || resp.Current == nil {
+		t.Fatalf("expected 2 schedules, 3 upcoming firings and the current slot, got %+v", resp)
+	}
+	if resp.Upcoming[0].Schedule == resp.Upcoming[1].Schedule || resp.Upcoming[0].Time.After(resp.Upcoming[1].Time) {
+		t.Errorf("expected alternating firings, soonest first, got %+v", resp.Upcoming)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/schedules?limit=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid limit, got %d", w.Code)
+	}
+}
+
+func TestGetSwitchJob_NotFound(t *testing.T) {
+	h, _ := setupTestHandler()
+
+	router := gin.New()
+	router.GET("/switch/jobs/:id", h.GetSwitchJob)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/switch/jobs/unknown", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestCancelSwitch_NoSwitchInProgress(t *testing.T) {
+	h, _ := setupTestHandler()
+
+	router := gin.New()
+	router.DELETE("/switch", h.CancelSwitch)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/switch", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestCancelSwitchJob(t *testing.T) {
+	h, _ := setupTestHandler()
+
+	router := gin.New()
+	router.DELETE("/switch/jobs/:id", h.CancelSwitchJob)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/switch/jobs/unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown job, got %d", w.Code)
+	}
+
+	// A finished job can't be canceled
+	job, err := h.switcher.StartSwitchJob(context.Background(), "model-a")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if j, _ := h.switcher.GetSwitchJob(job.ID); j.FinishedAt != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the switch job")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/switch/jobs/"+job.ID, nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for a finished job, got %d", w.Code)
+	}
+}
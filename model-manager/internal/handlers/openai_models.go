@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// ListModels returns the configured models in the OpenAI /v1/models format.
+// Disabled models are left out since they can never serve requests.
+func (h *Handler) ListModels(c *gin.Context) {
+	resp := h.switcher.GetModels()
+
+	data := make([]models.OpenAIModel, 0, len(resp.Models))
+	for i := range resp.Models {
+		m := &resp.Models[i]
+		status := m.GetStatus()
+		if status == models.StatusDisabled {
+			continue
+		}
+
+		lastActive := m.GetLastActive()
+		var created int64
+		if lastActive != nil {
+			created = lastActive.Unix()
+		}
+
+		data = append(data, models.OpenAIModel{
+			ID:      m.ID,
+			Object:  "model",
+			Created: created,
+			OwnedBy: "homegpt",
+			Metadata: models.OpenAIModelMetadata{
+				Name:        m.Name,
+				Status:      status,
+				GPUMemoryGB: m.GPUMemoryGB,
+				LastActive:  lastActive,
+			},
+		})
+	}
+
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].ID < data[j].ID
+	})
+
+	c.JSON(http.StatusOK, models.OpenAIModelList{
+		Object: "list",
+		Data:   data,
+	})
+}
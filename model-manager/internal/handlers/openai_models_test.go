@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zheng/homeGPT/internal/switcher"
+	"github.com/zheng/homeGPT/internal/vllm"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+func TestListModels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-b", Name: "Model B", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupSleep, GPUMemoryGB: 24.0},
+			{ID: "model-a", Name: "Model A", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive, GPUMemoryGB: 16.0},
+			{ID: "model-c", Name: "Model C", ContainerName: "vllm-c", Port: 8000, StartupMode: models.StartupDisabled, GPUMemoryGB: 36.0},
+		},
+	}
+
+	mockClient := vllm.NewMockClient()
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		return host == "vllm-b", nil
+	}
+
+	s := switcher.NewWithClient(cfg, mockClient)
+	s.WaitForInit()
+	h := New(s)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/v1/models", nil)
+
+	h.ListModels(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp models.OpenAIModelList
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	if resp.Object != "list" {
+		t.Errorf("expected object 'list', got '%s'", resp.Object)
+	}
+
+	// Disabled model-c is left out; the rest are sorted by ID
+	if len(resp.Data) != 2 {
+		t.Fatalf("expected 2 models, got %d", len(resp.Data))
+	}
+
+	a, b := resp.Data[0], resp.Data[1]
+	if a.ID != "model-a" || b.ID != "model-b" {
+		t.Errorf("expected [model-a model-b], got [%s %s]", a.ID, b.ID)
+	}
+
+	if a.Object != "model" || a.OwnedBy != "homegpt" {
+		t.Errorf("unexpected object/owned_by: %s/%s", a.Object, a.OwnedBy)
+	}
+
+	if a.Metadata.Status != models.StatusActive || a.Metadata.GPUMemoryGB != 16.0 || a.Metadata.Name != "Model A" {
+		t.Errorf("unexpected metadata for model-a: %+v", a.Metadata)
+	}
+
+	if a.Metadata.LastActive == nil || a.Created != a.Metadata.LastActive.Unix() {
+		t.Error("expected model-a created and last_active to be set")
+	}
+
+	if b.Metadata.Status != models.StatusSleeping {
+		t.Errorf("expected model-b to be sleeping, got %s", b.Metadata.Status)
+	}
+}
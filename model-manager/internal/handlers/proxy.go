@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zheng/homeGPT/internal/switcher"
+	"github.com/zheng/homeGPT/internal/vllm"
+)
+
+// inferenceRequest holds the fields of an OpenAI-style request body needed for routing
+type inferenceRequest struct {
+	Model  string `json:"model"`
+	Stream bool   `json:"stream"`
+}
+
+// ChatCompletions proxies POST /v1/chat/completions to the model named in the request
+func (h *Handler) ChatCompletions(c *gin.Context) {
+	h.proxyInference(c)
+}
+
+// Completions proxies POST /v1/completions to the model named in the request
+func (h *Handler) Completions(c *gin.Context) {
+	h.proxyInference(c)
+}
+
+// proxyInference looks up the target model from the request body and forwards
+// the request to its vLLM container. Sleeping models are woken first; models
+// that still cannot serve are refused here so they never reach a sleeping backend.
+func (h *Handler) proxyInference(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", "invalid_body", "failed to read request body")
+		return
+	}
+
+	var req inferenceRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", "invalid_body", "request body must be valid JSON")
+		return
+	}
+	if req.Model == "" {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", "model_required", "'model' is a required property")
+		return
+	}
+
+	// Sleeping models are switched in on demand; this blocks in the switcher's
+	// queue until the model is healthy
+	ctx := requestContext(c)
+	model, err := h.switcher.EnsureActive(ctx, req.Model)
+	if err != nil {
+		var queueErr *switcher.QueueError
+		var circuitErr *vllm.CircuitOpenError
+		switch {
+		case errors.Is(err, switcher.ErrHigherPriorityWork):
+			openAIError(c, http.StatusServiceUnavailable, "model_unavailable", "model_busy", err.Error())
+		case errors.As(err, &queueErr):
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(queueErr.RetryAfter.Seconds()))))
+			openAIError(c, http.StatusServiceUnavailable, "model_unavailable", "queue_"+queueErr.Reason, err.Error())
+		case errors.As(err, &circuitErr):
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(circuitErr.RetryAfter.Seconds()))))
+			openAIError(c, http.StatusServiceUnavailable, "model_unavailable", "circuit_open", err.Error())
+		case errors.Is(err, switcher.ErrModelNotFound):
+			openAIError(c, http.StatusNotFound, "invalid_request_error", "model_not_found",
+				fmt.Sprintf("model %s not found", req.Model))
+		default:
+			log.Printf("Model %s unavailable for inference: %v", req.Model, err)
+			openAIError(c, http.StatusServiceUnavailable, "model_unavailable", "model_not_active", err.Error())
+		}
+		return
+	}
+
+	// Keep the model from being put to sleep for idleness, or by lower-priority
+	// switches, while the request runs
+	done := h.switcher.TrackRequest(ctx, model.ID)
+	defer done()
+
+	target := &url.URL{
+		Scheme: "http",
+		Host:   fmt.Sprintf("%s:%d", model.ContainerName, model.Port),
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			if req.Stream {
+				// Streamed responses start right away, so their headers can time out
+				r.Out = r.Out.WithContext(vllm.WithStreaming(r.Out.Context()))
+			}
+		},
+		Transport: h.transport,
+		// Relay every chunk as soon as it arrives so SSE streams are never buffered
+		FlushInterval: -1,
+		ModifyResponse: func(resp *http.Response) error {
+			if req.Stream {
+				// Ask any reverse proxy in front of us not to buffer the stream either
+				resp.Header.Set("X-Accel-Buffering", "no")
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("Proxy to %s (%s) failed: %v", req.Model, target.Host, err)
+			openAIError(c, http.StatusBadGateway, "upstream_error", "upstream_unreachable",
+				fmt.Sprintf("model %s is unreachable", req.Model))
+		},
+	}
+
+	// Restore the body consumed while routing
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request.ContentLength = int64(len(body))
+
+	// ReverseProxy aborts with http.ErrAbortHandler when a relay breaks after the
+	// response has started (client disconnect or upstream idle timeout). The
+	// upstream request is already cancelled by then. The connection is dropped
+	// without ending the response, so a client can tell the cutoff from a
+	// finished stream; gin's recovery would otherwise end it cleanly.
+	defer func() {
+		if r := recover(); r != nil {
+			if r != http.ErrAbortHandler {
+				panic(r)
+			}
+			log.Printf("Relay for model %s aborted mid-response", req.Model)
+			if !closeConnection(c.Writer) {
+				panic(r)
+			}
+		}
+	}()
+
+	// The outgoing request uses c.Request's context, so a client disconnect
+	// cancels the upstream generation
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
+
+// closeConnection closes the client connection under a response that has
+// started, reporting false if the connection can't be taken over
+func closeConnection(w gin.ResponseWriter) bool {
+	// gin refuses to hijack a response that has started, so go around it
+	unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+	if !ok {
+		return false
+	}
+	conn, _, err := http.NewResponseController(unwrapper.Unwrap()).Hijack()
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// openAIError writes an error in the OpenAI API error format
+func openAIError(c *gin.Context, status int, errType, code, message string) {
+	c.JSON(status, gin.H{
+		"error": gin.H{
+			"message": message,
+			"type":    errType,
+			"code":    code,
+		},
+	})
+}
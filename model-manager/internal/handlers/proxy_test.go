@@ -0,0 +1,469 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zheng/homeGPT/internal/switcher"
+	"github.com/zheng/homeGPT/internal/vllm"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// backendAddr splits a test server URL into the host and port used as a model's container address
+func backendAddr(t *testing.T, backend *httptest.Server) (string, int) {
+	t.Helper()
+	u, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatalf("failed to parse backend URL: %v", err)
+	}
+	port, _ := strconv.Atoi(u.Port())
+	return u.Hostname(), port
+}
+
+// setupProxyHandler creates a handler with an active model-a and a sleeping model-b
+// served by the given backends, plus a sleeping model-c that fails to wake up
+func setupProxyHandler(t *testing.T, backendA, backendB *httptest.Server) (*Handler, *vllm.MockClient) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	hostA, portA := backendAddr(t, backendA)
+	hostB, portB := backendAddr(t, backendB)
+
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: hostA, Port: portA, StartupMode: models.StartupActive},
+			{ID: "model-b", ContainerName: hostB, Port: portB, StartupMode: models.StartupSleep},
+			{ID: "model-c", ContainerName: "vllm-c", Port: 8000, StartupMode: models.StartupSleep},
+		},
+	}
+
+	// Track sleep state per port so Sleep/WakeUp calls are reflected by IsSleeping
+	var mu sync.Mutex
+	sleeping := map[int]bool{portB: true}
+
+	mockClient := vllm.NewMockClient()
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		if host == "vllm-c" {
+			return true, nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return sleeping[port], nil
+	}
+	mockClient.SleepFunc = func(ctx context.Context, host string, port int, level int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sleeping[port] = true
+		return nil
+	}
+	mockClient.WakeUpFunc = func(ctx context.Context, host string, port int) error {
+		if host == "vllm-c" {
+			return errors.New("wake up failed")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		sleeping[port] = false
+		return nil
+	}
+
+	s := switcher.NewWithClient(cfg, mockClient, switcher.WithMaxRetries(2), switcher.WithHealthCheckInterval(10*time.Millisecond))
+	s.WaitForInit()
+
+	return New(s), mockClient
+}
+
+func newBackend(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	backend := httptest.NewServer(handler)
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func noopBackend(t *testing.T) *httptest.Server {
+	return newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+}
+
+// closeNotifyRecorder adds http.CloseNotifier to httptest.ResponseRecorder, which
+// gin's response writer assumes is present when httputil.ReverseProxy asks for it
+type closeNotifyRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r *closeNotifyRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func doInferenceRequest(h *Handler, path string, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(&closeNotifyRecorder{w})
+
+	req := httptest.NewRequest("POST", path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	c.Request = req
+
+	if path == "/v1/completions" {
+		h.Completions(c)
+	} else {
+		h.ChatCompletions(c)
+	}
+	return w
+}
+
+func TestChatCompletions_ForwardsToActiveModel(t *testing.T) {
+	var gotPath string
+	var gotBody []byte
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion"}`))
+	})
+
+	h, _ := setupProxyHandler(t, backend, noopBackend(t))
+
+	body := `{"model":"model-a","messages":[{"role":"user","content":"hi"}]}`
+	w := doInferenceRequest(h, "/v1/chat/completions", body)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if gotPath != "/v1/chat/completions" {
+		t.Errorf("expected backend path '/v1/chat/completions', got '%s'", gotPath)
+	}
+
+	if string(gotBody) != body {
+		t.Errorf("expected body to be forwarded unchanged, got '%s'", string(gotBody))
+	}
+
+	var resp map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	if resp["id"] != "chatcmpl-1" {
+		t.Errorf("expected backend response to be relayed, got %v", resp)
+	}
+}
+
+func TestCompletions_ForwardsToActiveModel(t *testing.T) {
+	var gotPath string
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Write([]byte(`{}`))
+	})
+
+	h, _ := setupProxyHandler(t, backend, noopBackend(t))
+
+	w := doInferenceRequest(h, "/v1/completions", `{"model":"model-a","prompt":"hi"}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	if gotPath != "/v1/completions" {
+		t.Errorf("expected backend path '/v1/completions', got '%s'", gotPath)
+	}
+}
+
+func TestChatCompletions_SleepingModelSwitchesOnDemand(t *testing.T) {
+	calledA := false
+	calledB := false
+	backendA := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		calledA = true
+	})
+	backendB := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		calledB = true
+		w.Write([]byte(`{}`))
+	})
+
+	h, mockClient := setupProxyHandler(t, backendA, backendB)
+
+	w := doInferenceRequest(h, "/v1/chat/completions", `{"model":"model-b","messages":[]}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if !calledB || calledA {
+		t.Errorf("expected request to reach model-b only (a=%v, b=%v)", calledA, calledB)
+	}
+
+	if len(mockClient.WakeUpCalls) != 1 {
+		t.Errorf("expected 1 wake_up call, got %d", len(mockClient.WakeUpCalls))
+	}
+
+	if active := h.switcher.GetModels().ActiveModel; active != "model-b" {
+		t.Errorf("expected active model 'model-b', got '%s'", active)
+	}
+}
+
+func TestChatCompletions_SwitchFailureRefused(t *testing.T) {
+	h, mockClient := setupProxyHandler(t, noopBackend(t), noopBackend(t))
+
+	w := doInferenceRequest(h, "/v1/chat/completions", `{"model":"model-c","messages":[]}`)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+
+	var resp map[string]map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	if resp["error"]["code"] != "model_not_active" {
+		t.Errorf("expected error code 'model_not_active', got '%s'", resp["error"]["code"])
+	}
+
+	// model-c is now in error state and must be refused without another wake-up attempt
+	mockClient.Reset()
+	w = doInferenceRequest(h, "/v1/chat/completions", `{"model":"model-c","messages":[]}`)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 for errored model, got %d", w.Code)
+	}
+
+	if len(mockClient.WakeUpCalls) != 0 {
+		t.Errorf("expected no wake_up calls for an errored model, got %d", len(mockClient.WakeUpCalls))
+	}
+}
+
+func TestChatCompletions_UnknownModel(t *testing.T) {
+	h, _ := setupProxyHandler(t, noopBackend(t), noopBackend(t))
+
+	w := doInferenceRequest(h, "/v1/chat/completions", `{"model":"nonexistent"}`)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestChatCompletions_InvalidBody(t *testing.T) {
+	h, _ := setupProxyHandler(t, noopBackend(t), noopBackend(t))
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "invalid json", body: "invalid json"},
+		{name: "missing model", body: `{"messages":[]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doInferenceRequest(h, "/v1/chat/completions", tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestChatCompletions_BackendUnreachable(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h, _ := setupProxyHandler(t, backend, noopBackend(t))
+	backend.Close()
+
+	w := doInferenceRequest(h, "/v1/chat/completions", `{"model":"model-a"}`)
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", w.Code)
+	}
+}
+
+func TestChatCompletions_QueueTimeoutSetsRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupSleep},
+		},
+	}
+
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	mockClient := vllm.NewMockClient()
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		return host == "vllm-b" || len(mockClient.SleepCalls) > 0, nil
+	}
+	mockClient.WakeUpFunc = func(ctx context.Context, host string, port int) error {
+		<-unblock
+		return nil
+	}
+
+	s := switcher.NewWithClient(cfg, mockClient,
+		switcher.WithMaxRetries(2),
+		switcher.WithHealthCheckInterval(10*time.Millisecond),
+		switcher.WithQueueLimits(4, 20*time.Millisecond))
+	s.WaitForInit()
+	h := New(s)
+
+	w := doInferenceRequest(h, "/v1/chat/completions", `{"model":"model-b","messages":[]}`)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", w.Code)
+	}
+
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+
+	var resp map[string]map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	if resp["error"]["code"] != "queue_timeout" {
+		t.Errorf("expected error code 'queue_timeout', got '%s'", resp["error"]["code"])
+	}
+}
+
+// newProxyServer serves the handler's inference routes over a real HTTP server,
+// which is needed to observe streaming and client disconnects
+func newProxyServer(t *testing.T, h *Handler) *httptest.Server {
+	t.Helper()
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.POST("/v1/chat/completions", h.ChatCompletions)
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestChatCompletions_StreamsWithoutBuffering(t *testing.T) {
+	proceed := make(chan struct{})
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"chunk\":1}\n\n"))
+		w.(http.Flusher).Flush()
+
+		// Hold the rest of the stream until the client has seen the first chunk
+		select {
+		case <-proceed:
+		case <-time.After(2 * time.Second):
+			return
+		}
+		w.Write([]byte("data: [DONE]\n\n"))
+	})
+
+	h, _ := setupProxyHandler(t, backend, noopBackend(t))
+	server := newProxyServer(t, h)
+
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+		bytes.NewBufferString(`{"model":"model-a","stream":true,"messages":[]}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("X-Accel-Buffering") != "no" {
+		t.Error("expected X-Accel-Buffering: no on streamed response")
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "data: {\"chunk\":1}\n" {
+		t.Fatalf("expected first chunk before the stream finished, got %q (%v)", line, err)
+	}
+
+	close(proceed)
+
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read rest of stream: %v", err)
+	}
+	if !strings.Contains(string(rest), "data: [DONE]") {
+		t.Errorf("expected stream to end with [DONE], got %q", string(rest))
+	}
+}
+
+func TestChatCompletions_ClientDisconnectCancelsUpstream(t *testing.T) {
+	upstreamCancelled := make(chan struct{})
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"chunk\":1}\n\n"))
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-r.Context().Done():
+			close(upstreamCancelled)
+		case <-time.After(2 * time.Second):
+		}
+	})
+
+	h, _ := setupProxyHandler(t, backend, noopBackend(t))
+	server := newProxyServer(t, h)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "POST", server.URL+"/v1/chat/completions",
+		bytes.NewBufferString(`{"model":"model-a","stream":true,"messages":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if _, err := bufio.NewReader(resp.Body).ReadString('\n'); err != nil {
+		t.Fatalf("failed to read first chunk: %v", err)
+	}
+
+	cancel()
+	resp.Body.Close()
+
+	select {
+	case <-upstreamCancelled:
+	case <-time.After(time.Second):
+		t.Fatal("expected client disconnect to cancel the upstream request")
+	}
+}
+
+func TestChatCompletions_IdleUpstreamTruncatesStream(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"chunk\":1}\n\n"))
+		w.(http.Flusher).Flush()
+
+		// Stall past the idle timeout
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	})
+
+	h, _ := setupProxyHandler(t, backend, noopBackend(t))
+	h.transport = vllm.NewStreamTransport(50 * time.Millisecond)
+	server := newProxyServer(t, h)
+
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+		bytes.NewBufferString(`{"model":"model-a","stream":true,"messages":[]}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The cutoff shows up as a broken response rather than a finished stream
+	body, err := io.ReadAll(resp.Body)
+	if err == nil {
+		t.Fatalf("expected the stream to be cut off, got a complete response %q", string(body))
+	}
+	if !strings.Contains(string(body), "chunk") {
+		t.Errorf("expected the first chunk before the cutoff, got %q", string(body))
+	}
+}
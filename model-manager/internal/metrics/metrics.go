@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+const namespace = "homegpt"
+
+// allStatuses are reported for every model so each status series always exists
+var allStatuses = []models.ModelStatus{
+	models.StatusActive,
+	models.StatusSleeping,
+	models.StatusSwitching,
+	models.StatusError,
+	models.StatusDisabled,
+}
+
+// Metrics holds the model manager's Prometheus metrics. A nil *Metrics is valid
+// and records nothing, so callers don't need to check whether metrics are enabled.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	switchDuration      *prometheus.HistogramVec
+	switches            *prometheus.CounterVec
+	rollbacks           prometheus.Counter
+	healthCheckAttempts prometheus.Histogram
+	resyncErrors        *prometheus.CounterVec
+	sleepLevel          *prometheus.GaugeVec
+	transitions         *prometheus.CounterVec
+	splitBrain          *prometheus.CounterVec
+	thrashing           prometheus.Counter
+}
+
+// New creates the metrics and registers them, along with Go runtime and process
+// metrics, on a dedicated registry
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		switchDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "switch_phase_duration_seconds",
+			Help:      "Time spent in each phase of a model switch.",
+			Buckets:   []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300, 600, 900},
+		}, []string{"phase"}),
+		switches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "switches_total",
+			Help:      "Model switches by target model and result.",
+		}, []string{"model", "result"}),
+		rollbacks: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "switch_rollbacks_total",
+			Help:      "Failed switches that reactivated the models they had put to sleep.",
+		}),
+		healthCheckAttempts: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "health_check_attempts",
+			Help:      "Health check attempts until a model became ready.",
+			Buckets:   []float64{1, 2, 3, 5, 10, 20, 50, 100, 200, 450},
+		}),
+		resyncErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "resync_errors_total",
+			Help:      "Failures to query a model's state during resync.",
+		}, []string{"model"}),
+		sleepLevel: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sleep_level",
+			Help:      "Sleep level (1 = offload to CPU RAM, 2 = discard weights) last chosen for a model.",
+		}, []string{"model"}),
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "model_status_transitions_total",
+			Help:      "Model status changes by cause; a fast-growing rate indicates a flapping model.",
+		}, []string{"model", "from", "to", "cause"}),
+		splitBrain: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "split_brain_evictions_total",
+			Help:      "Models resync found awake alongside models they don't fit with, and put to sleep.",
+		}, []string{"model"}),
+		thrashing: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "switch_thrashing_total",
+			Help:      "Times the thrash detector found more switches within its window than allowed.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.switchDuration,
+		m.switches,
+		m.rollbacks,
+		m.healthCheckAttempts,
+		m.resyncErrors,
+		m.sleepLevel,
+		m.transitions,
+		m.splitBrain,
+		m.thrashing,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Handler serves the metrics in Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterModels reports homegpt_model_status from the given source at scrape time
+func (m *Metrics) RegisterModels(source func() []models.Model) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(&statusCollector{source: source})
+}
+
+// ObserveSwitchPhase records how long a switch phase ("sleep" or "wake") took
+func (m *Metrics) ObserveSwitchPhase(phase string, seconds float64) {
+	if m == nil {
+		return
+	}
+	m.switchDuration.WithLabelValues(phase).Observe(seconds)
+}
+
+// SwitchFinished counts a switch to modelID that succeeded or failed
+func (m *Metrics) SwitchFinished(modelID string, err error) {
+	if m == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.switches.WithLabelValues(modelID, result).Inc()
+}
+
+// SwitchRolledBack counts a failed switch that reactivated evicted models
+func (m *Metrics) SwitchRolledBack() {
+	if m == nil {
+		return
+	}
+	m.rollbacks.Inc()
+}
+
+// ObserveHealthCheckAttempts records how many health checks a model needed to become ready
+func (m *Metrics) ObserveHealthCheckAttempts(attempts int) {
+	if m == nil {
+		return
+	}
+	m.healthCheckAttempts.Observe(float64(attempts))
+}
+
+// ResyncError counts a failure to query a model during resync
+func (m *Metrics) ResyncError(modelID string) {
+	if m == nil {
+		return
+	}
+	m.resyncErrors.WithLabelValues(modelID).Inc()
+}
+
+// SplitBrainEviction counts a model put to sleep because resync found it awake
+// alongside models it doesn't fit with
+func (m *Metrics) SplitBrainEviction(modelID string) {
+	if m == nil {
+		return
+	}
+	m.splitBrain.WithLabelValues(modelID).Inc()
+}
+
+// Thrashing counts a trip of the thrash detector
+func (m *Metrics) Thrashing() {
+	if m == nil {
+		return
+	}
+	m.thrashing.Inc()
+}
+
+// SetSleepLevel records the sleep level chosen for a model
+func (m *Metrics) SetSleepLevel(modelID string, level int) {
+	if m == nil {
+		return
+	}
+	m.sleepLevel.WithLabelValues(modelID).Set(float64(level))
+}
+
+// ObserveTransition counts a model status change
+func (m *Metrics) ObserveTransition(event models.StatusEvent) {
+	if m == nil {
+		return
+	}
+	m.transitions.WithLabelValues(event.ModelID, string(event.OldStatus), string(event.NewStatus), string(event.Cause)).Inc()
+}
+
+// statusCollector exports one series per model and status, set to 1 for the
+// model's current status and 0 otherwise
+type statusCollector struct {
+	source func() []models.Model
+}
+
+var modelStatusDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "model_status"),
+	"Current model status (1 for the status the model is in, 0 otherwise).",
+	[]string{"model", "status"}, nil,
+)
+
+var modelGPUMemoryDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "model_gpu_memory_gb"),
+	"GPU memory a model uses when active, in GB.",
+	[]string{"model"}, nil,
+)
+
+func (c *statusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- modelStatusDesc
+	ch <- modelGPUMemoryDesc
+}
+
+func (c *statusCollector) Collect(ch chan<- prometheus.Metric) {
+	list := c.source()
+	for i := range list {
+		model := &list[i]
+		current := model.GetStatus()
+		for _, status := range allStatuses {
+			value := 0.0
+			if status == current {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(modelStatusDesc, prometheus.GaugeValue, value, model.ID, string(status))
+		}
+		ch <- prometheus.MustNewConstMetric(modelGPUMemoryDesc, prometheus.GaugeValue, model.GPUMemoryGB, model.ID)
+	}
+}
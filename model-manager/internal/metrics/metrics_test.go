@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// scrape returns the metrics exposition text
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	return string(body)
+}
+
+func TestMetrics_Exposition(t *testing.T) {
+	m := New()
+
+	m.ObserveSwitchPhase("sleep", 1.5)
+	m.ObserveSwitchPhase("wake", 12)
+	m.SwitchFinished("model-b", nil)
+	m.SwitchFinished("model-c", errors.New("wake up failed"))
+	m.SwitchRolledBack()
+	m.ObserveHealthCheckAttempts(7)
+	m.ResyncError("model-a")
+	m.SplitBrainEviction("model-b")
+	m.Thrashing()
+	m.SetSleepLevel("model-a", 2)
+	m.ObserveTransition(models.StatusEvent{ModelID: "model-a", OldStatus: models.StatusActive, NewStatus: models.StatusSleeping, Cause: models.CauseIdle})
+
+	body := scrape(t, m)
+
+	expected := []string{
+		`homegpt_switch_phase_duration_seconds_count{phase="sleep"} 1`,
+		`homegpt_switch_phase_duration_seconds_count{phase="wake"} 1`,
+		`homegpt_switches_total{model="model-b",result="success"} 1`,
+		`homegpt_switches_total{model="model-c",result="failure"} 1`,
+		`homegpt_switch_rollbacks_total 1`,
+		`homegpt_health_check_attempts_sum 7`,
+		`homegpt_resync_errors_total{model="model-a"} 1`,
+		`homegpt_split_brain_evictions_total{model="model-b"} 1`,
+		`homegpt_switch_thrashing_total 1`,
+		`homegpt_sleep_level{model="model-a"} 2`,
+		`homegpt_model_status_transitions_total{cause="idle",from="active",model="model-a",to="sleeping"} 1`,
+		`go_goroutines`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q", want)
+		}
+	}
+}
+
+func TestMetrics_ModelStatus(t *testing.T) {
+	m := New()
+
+	a := models.Model{ID: "model-a", GPUMemoryGB: 36}
+	a.MarkActive()
+	m.RegisterModels(func() []models.Model {
+		return []models.Model{a.Snapshot()}
+	})
+
+	body := scrape(t, m)
+
+	expected := []string{
+		`homegpt_model_status{model="model-a",status="active"} 1`,
+		`homegpt_model_status{model="model-a",status="sleeping"} 0`,
+		`homegpt_model_gpu_memory_gb{model="model-a"} 36`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q", want)
+		}
+	}
+}
+
+func TestMetrics_NilIsNoop(t *testing.T) {
+	var m *Metrics
+
+	// None of these may panic
+	m.ObserveSwitchPhase("wake", 1)
+	m.SwitchFinished("model-a", nil)
+	m.SwitchRolledBack()
+	m.ObserveHealthCheckAttempts(1)
+	m.ResyncError("model-a")
+	m.SplitBrainEviction("model-a")
+	m.Thrashing()
+	m.SetSleepLevel("model-a", 1)
+	m.ObserveTransition(models.StatusEvent{})
+	m.RegisterModels(nil)
+}
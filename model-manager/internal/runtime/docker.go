@@ -0,0 +1,189 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultDockerSocket is where the Docker Engine API listens by default
+const DefaultDockerSocket = "/var/run/docker.sock"
+
+// dockerAPIBase is the base URL for Engine API requests; the host is ignored
+// since every connection goes to the unix socket
+const dockerAPIBase = "http://docker/v1.43"
+
+// DockerRuntime is a ContainerRuntime backed by the Docker Engine API
+type DockerRuntime struct {
+	httpClient *http.Client
+}
+
+// NewDocker creates a Docker runtime that talks to the Engine API on a unix socket
+func NewDocker(socketPath string) *DockerRuntime {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}
+
+	return &DockerRuntime{
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   2 * time.Minute, // Stop may wait out the container's grace period
+		},
+	}
+}
+
+// State returns the container's state
+func (d *DockerRuntime) State(ctx context.Context, name string) (State, error) {
+	resp, err := d.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(name)+"/json", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return StateMissing, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", dockerError(resp, "inspect", name)
+	}
+
+	var inspect struct {
+		State struct {
+			Status string `json:"Status"`
+		} `json:"State"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&inspect); err != nil {
+		return "", fmt.Errorf("failed to decode inspect response for %s: %w", name, err)
+	}
+
+	return State(inspect.State.Status), nil
+}
+
+// Create creates a container from the spec
+func (d *DockerRuntime) Create(ctx context.Context, spec ContainerSpec) error {
+	type deviceRequest struct {
+		Driver       string     `json:"Driver"`
+		Count        int        `json:"Count,omitempty"`
+		DeviceIDs    []string   `json:"DeviceIDs,omitempty"`
+		Capabilities [][]string `json:"Capabilities"`
+	}
+
+	gpus := deviceRequest{Driver: "nvidia", Capabilities: [][]string{{"gpu"}}}
+	if len(spec.GPUs) == 0 {
+		gpus.Count = -1 // All GPUs
+	}
+	for _, id := range spec.GPUs {
+		gpus.DeviceIDs = append(gpus.DeviceIDs, strconv.Itoa(id))
+	}
+
+	body := map[string]any{
+		"Image": spec.Image,
+		"Cmd":   spec.Command,
+		"Env":   spec.Env,
+		"HostConfig": map[string]any{
+			"Binds":          spec.Volumes,
+			"NetworkMode":    spec.Network,
+			"IpcMode":        "host",
+			"DeviceRequests": []deviceRequest{gpus},
+		},
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode create request for %s: %w", spec.Name, err)
+	}
+
+	resp, err := d.do(ctx, http.MethodPost, "/containers/create?name="+url.QueryEscape(spec.Name), payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return dockerError(resp, "create", spec.Name)
+	}
+	return nil
+}
+
+// Start starts a container
+func (d *DockerRuntime) Start(ctx context.Context, name string) error {
+	resp, err := d.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(name)+"/start", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusNotModified: // 304: already running
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	default:
+		return dockerError(resp, "start", name)
+	}
+}
+
+// Stop stops a container
+func (d *DockerRuntime) Stop(ctx context.Context, name string, timeout time.Duration) error {
+	path := fmt.Sprintf("/containers/%s/stop?t=%d", url.PathEscape(name), int(timeout.Seconds()))
+	resp, err := d.do(ctx, http.MethodPost, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusNotModified: // 304: already stopped
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	default:
+		return dockerError(resp, "stop", name)
+	}
+}
+
+// do sends a request to the Engine API
+func (d *DockerRuntime) do(ctx context.Context, method, path string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, dockerAPIBase+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// dockerError builds an error from an Engine API error response
+func dockerError(resp *http.Response, op, name string) error {
+	var apiErr struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Message == "" {
+		return fmt.Errorf("docker %s %s: unexpected status code %d", op, name, resp.StatusCode)
+	}
+	return fmt.Errorf("docker %s %s: %s (status %d)", op, name, apiErr.Message, resp.StatusCode)
+}
+
+// Ensure DockerRuntime implements ContainerRuntime
+var _ ContainerRuntime = (*DockerRuntime)(nil)
@@ -0,0 +1,136 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newDockerServer serves handler on a unix socket and returns a runtime connected to it
+func newDockerServer(t *testing.T, handler http.Handler) *DockerRuntime {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("failed to listen on unix socket: %v", err)
+	}
+
+	server := &http.Server{Handler: handler}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	return NewDocker(socketPath)
+}
+
+func TestDockerRuntime_State(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1.43/containers/vllm-a/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"State":{"Status":"exited"}}`))
+	})
+	mux.HandleFunc("GET /v1.43/containers/vllm-b/json", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"No such container: vllm-b"}`))
+	})
+
+	d := newDockerServer(t, mux)
+
+	state, err := d.State(context.Background(), "vllm-a")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if state != StateExited {
+		t.Errorf("expected exited, got %s", state)
+	}
+
+	state, err = d.State(context.Background(), "vllm-b")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if state != StateMissing {
+		t.Errorf("expected missing, got %s", state)
+	}
+}
+
+func TestDockerRuntime_Create(t *testing.T) {
+	var got map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1.43/containers/create", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") != "vllm-a" {
+			t.Errorf("expected name vllm-a, got %s", r.URL.Query().Get("name"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Id":"abc"}`))
+	})
+
+	d := newDockerServer(t, mux)
+
+	spec := ContainerSpec{
+		Name:    "vllm-a",
+		Image:   "vllm/vllm-openai:v0.11.1",
+		Command: []string{"openai/gpt-oss-20b", "--enable-sleep-mode"},
+		Network: "homegpt-network",
+		GPUs:    []int{0, 1},
+	}
+	if err := d.Create(context.Background(), spec); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if got["Image"] != "vllm/vllm-openai:v0.11.1" {
+		t.Errorf("unexpected image: %v", got["Image"])
+	}
+
+	hostConfig := got["HostConfig"].(map[string]any)
+	if hostConfig["NetworkMode"] != "homegpt-network" {
+		t.Errorf("unexpected network: %v", hostConfig["NetworkMode"])
+	}
+
+	devices := hostConfig["DeviceRequests"].([]any)[0].(map[string]any)
+	ids := devices["DeviceIDs"].([]any)
+	if len(ids) != 2 || ids[0] != "0" || ids[1] != "1" {
+		t.Errorf("unexpected device IDs: %v", ids)
+	}
+}
+
+func TestDockerRuntime_StartStop(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1.43/containers/vllm-a/start", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /v1.43/containers/vllm-a/stop", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("t") != "30" {
+			t.Errorf("expected stop timeout 30, got %s", r.URL.Query().Get("t"))
+		}
+		w.WriteHeader(http.StatusNotModified)
+	})
+	mux.HandleFunc("POST /v1.43/containers/vllm-b/start", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"No such container: vllm-b"}`))
+	})
+	mux.HandleFunc("POST /v1.43/containers/vllm-c/start", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"message":"could not select device driver"}`))
+	})
+
+	d := newDockerServer(t, mux)
+	ctx := context.Background()
+
+	if err := d.Start(ctx, "vllm-a"); err != nil {
+		t.Errorf("expected no error starting vllm-a, got %v", err)
+	}
+	if err := d.Stop(ctx, "vllm-a", 30*time.Second); err != nil {
+		t.Errorf("expected no error stopping already-stopped vllm-a, got %v", err)
+	}
+	if err := d.Start(ctx, "vllm-b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for vllm-b, got %v", err)
+	}
+	if err := d.Start(ctx, "vllm-c"); err == nil {
+		t.Error("expected error for vllm-c, got nil")
+	}
+}
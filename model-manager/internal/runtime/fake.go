@@ -0,0 +1,99 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FakeRuntime is an in-memory ContainerRuntime for testing
+type FakeRuntime struct {
+	mu sync.Mutex
+
+	// Container states by name; containers not in the map are missing
+	states map[string]State
+
+	// Optional hooks to inject failures; they run before the state changes
+	StartFunc func(ctx context.Context, name string) error
+	StopFunc  func(ctx context.Context, name string) error
+
+	// Call tracking
+	CreateCalls []ContainerSpec
+	StartCalls  []string
+	StopCalls   []string
+}
+
+// NewFakeRuntime creates a fake runtime with the given initial container states
+func NewFakeRuntime(states map[string]State) *FakeRuntime {
+	f := &FakeRuntime{states: make(map[string]State)}
+	for name, state := range states {
+		f.states[name] = state
+	}
+	return f
+}
+
+func (f *FakeRuntime) State(ctx context.Context, name string) (State, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if state, ok := f.states[name]; ok {
+		return state, nil
+	}
+	return StateMissing, nil
+}
+
+func (f *FakeRuntime) Create(ctx context.Context, spec ContainerSpec) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.CreateCalls = append(f.CreateCalls, spec)
+	if _, ok := f.states[spec.Name]; ok {
+		return fmt.Errorf("container %s already exists", spec.Name)
+	}
+	f.states[spec.Name] = StateCreated
+	return nil
+}
+
+func (f *FakeRuntime) Start(ctx context.Context, name string) error {
+	f.mu.Lock()
+	f.StartCalls = append(f.StartCalls, name)
+	f.mu.Unlock()
+
+	if f.StartFunc != nil {
+		if err := f.StartFunc(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.states[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	f.states[name] = StateRunning
+	return nil
+}
+
+func (f *FakeRuntime) Stop(ctx context.Context, name string, timeout time.Duration) error {
+	f.mu.Lock()
+	f.StopCalls = append(f.StopCalls, name)
+	f.mu.Unlock()
+
+	if f.StopFunc != nil {
+		if err := f.StopFunc(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.states[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	f.states[name] = StateExited
+	return nil
+}
+
+// Ensure FakeRuntime implements ContainerRuntime
+var _ ContainerRuntime = (*FakeRuntime)(nil)
@@ -0,0 +1,43 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// State is the lifecycle state of a container as reported by the runtime
+type State string
+
+const (
+	StateMissing State = "missing" // No container with that name exists
+	StateCreated State = "created"
+	StateRunning State = "running"
+	StateExited  State = "exited"
+)
+
+// ErrNotFound is returned when an operation targets a container that doesn't exist
+var ErrNotFound = errors.New("container not found")
+
+// ContainerSpec describes a container to create for a model
+type ContainerSpec struct {
+	Name    string
+	Image   string
+	Command []string
+	Env     []string
+	Volumes []string // host:container[:mode] bind mounts
+	Network string
+	GPUs    []int // CUDA device IDs to expose (empty = all GPUs)
+}
+
+// ContainerRuntime starts and stops the containers that serve models
+type ContainerRuntime interface {
+	// State returns the container's state, or StateMissing if it doesn't exist
+	State(ctx context.Context, name string) (State, error)
+	// Create creates a stopped container from the spec
+	Create(ctx context.Context, spec ContainerSpec) error
+	// Start starts an existing container; starting a running container is not an error
+	Start(ctx context.Context, name string) error
+	// Stop stops a running container, killing it after the timeout
+	Stop(ctx context.Context, name string, timeout time.Duration) error
+}
@@ -0,0 +1,161 @@
+package state
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	metaBucket    = []byte("meta")
+	modelsBucket  = []byte("models")
+	historyBucket = []byte("history")
+
+	activeModelsKey = []byte("active_models")
+)
+
+// BoltStore is a Store backed by a BoltDB file
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens (creating if needed) the state file at path. Only one process
+// can hold the file open; another waits up to a second before giving up.
+func OpenBolt(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state file %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{metaBucket, modelsBucket, historyBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize state file %s: %w", path, err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (b *BoltStore) Load() (State, error) {
+	st := State{Models: make(map[string]ModelState)}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket(metaBucket).Get(activeModelsKey); data != nil {
+			if err := json.Unmarshal(data, &st.ActiveModels); err != nil {
+				return fmt.Errorf("failed to decode active models: %w", err)
+			}
+		}
+
+		return tx.Bucket(modelsBucket).ForEach(func(k, v []byte) error {
+			var ms ModelState
+			if err := json.Unmarshal(v, &ms); err != nil {
+				return fmt.Errorf("failed to decode state of model %s: %w", k, err)
+			}
+			st.Models[string(k)] = ms
+			return nil
+		})
+	})
+	if err != nil {
+		return State{}, err
+	}
+	return st, nil
+}
+
+func (b *BoltStore) SaveActiveModels(ids []string) error {
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return fmt.Errorf("failed to encode active models: %w", err)
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(activeModelsKey, data)
+	})
+}
+
+func (b *BoltStore) SaveModel(id string, state ModelState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode state of model %s: %w", id, err)
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(modelsBucket).Put([]byte(id), data)
+	})
+}
+
+func (b *BoltStore) AppendSwitch(record models.SwitchRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode switch record: %w", err)
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(historyBucket)
+
+		// Big-endian sequence keys keep records in insertion order
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(sequenceKey(seq), data); err != nil {
+			return err
+		}
+
+		// Drop the records that fell out of the last MaxHistory
+		if seq <= MaxHistory {
+			return nil
+		}
+		cutoff := seq - MaxHistory
+		c := bucket.Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) <= cutoff; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *BoltStore) History(limit int) ([]models.SwitchRecord, error) {
+	var records []models.SwitchRecord
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(historyBucket).Cursor()
+		for k, v := c.Last(); k != nil && (limit <= 0 || len(records) < limit); k, v = c.Prev() {
+			var record models.SwitchRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("failed to decode switch record: %w", err)
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+func sequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// Ensure BoltStore implements Store
+var _ Store = (*BoltStore)(nil)
@@ -0,0 +1,84 @@
+package state
+
+import (
+	"sync"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// MemoryStore is a Store that keeps state in memory only, for testing and for
+// running without a state file
+type MemoryStore struct {
+	mu           sync.Mutex
+	activeModels []string
+	models       map[string]ModelState
+	history      []models.SwitchRecord // Oldest first
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *MemoryStore {
+	return &MemoryStore{models: make(map[string]ModelState)}
+}
+
+func (m *MemoryStore) Load() (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := State{
+		ActiveModels: append([]string(nil), m.activeModels...),
+		Models:       make(map[string]ModelState, len(m.models)),
+	}
+	for id, ms := range m.models {
+		st.Models[id] = ms
+	}
+	return st, nil
+}
+
+func (m *MemoryStore) SaveActiveModels(ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.activeModels = append([]string(nil), ids...)
+	return nil
+}
+
+func (m *MemoryStore) SaveModel(id string, state ModelState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.models[id] = state
+	return nil
+}
+
+func (m *MemoryStore) AppendSwitch(record models.SwitchRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.history = append(m.history, record)
+	if len(m.history) > MaxHistory {
+		m.history = m.history[len(m.history)-MaxHistory:]
+	}
+	return nil
+}
+
+func (m *MemoryStore) History(limit int) ([]models.SwitchRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := len(m.history)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	records := make([]models.SwitchRecord, 0, n)
+	for i := len(m.history) - 1; i >= 0 && len(records) < n; i-- {
+		records = append(records, m.history[i])
+	}
+	return records, nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// Ensure MemoryStore implements Store
+var _ Store = (*MemoryStore)(nil)
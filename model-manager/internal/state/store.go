@@ -0,0 +1,38 @@
+package state
+
+import (
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// MaxHistory is how many switch records a store keeps; older ones are dropped
+const MaxHistory = 1000
+
+// ModelState is what is remembered about a model across restarts
+type ModelState struct {
+	LastActive *time.Time `json:"last_active,omitempty"`
+	ErrorCount int        `json:"error_count"`
+}
+
+// State is the persisted switcher state
+type State struct {
+	ActiveModels []string              // Awake models, least recently used first
+	Models       map[string]ModelState // Per-model state by model ID
+}
+
+// Store persists switcher state so a restart can restore it
+type Store interface {
+	// Load returns the persisted state; an empty store returns an empty State
+	Load() (State, error)
+	// SaveActiveModels replaces the set of awake models
+	SaveActiveModels(ids []string) error
+	// SaveModel replaces a model's state
+	SaveModel(id string, state ModelState) error
+	// AppendSwitch adds a completed switch to the history, keeping the last MaxHistory
+	AppendSwitch(record models.SwitchRecord) error
+	// History returns up to limit switch records, newest first (limit <= 0 = all)
+	History(limit int) ([]models.SwitchRecord, error)
+	// Close releases the store
+	Close() error
+}
@@ -0,0 +1,134 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// stores returns a fresh instance of every Store implementation
+func stores(t *testing.T) map[string]func() Store {
+	t.Helper()
+
+	return map[string]func() Store{
+		"memory": func() Store { return NewMemory() },
+		"bolt": func() Store {
+			store, err := OpenBolt(filepath.Join(t.TempDir(), "state.db"))
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+	}
+}
+
+func TestStore_SaveAndLoad(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+
+			empty, err := store.Load()
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(empty.ActiveModels) != 0 || len(empty.Models) != 0 {
+				t.Errorf("expected empty state, got %+v", empty)
+			}
+
+			lastActive := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+			if err := store.SaveActiveModels([]string{"model-a", "model-b"}); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if err := store.SaveModel("model-a", ModelState{LastActive: &lastActive, ErrorCount: 2}); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			st, err := store.Load()
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(st.ActiveModels) != 2 || st.ActiveModels[0] != "model-a" || st.ActiveModels[1] != "model-b" {
+				t.Errorf("expected active models [model-a model-b], got %v", st.ActiveModels)
+			}
+			ms := st.Models["model-a"]
+			if ms.ErrorCount != 2 {
+				t.Errorf("expected error count 2, got %d", ms.ErrorCount)
+			}
+			if ms.LastActive == nil || !ms.LastActive.Equal(lastActive) {
+				t.Errorf("expected last active %v, got %v", lastActive, ms.LastActive)
+			}
+		})
+	}
+}
+
+func TestStore_History(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+
+			for i := 0; i < MaxHistory+5; i++ {
+				record := models.SwitchRecord{
+					ModelID:   "model-a",
+					StartedAt: time.Unix(int64(i), 0),
+					Success:   true,
+				}
+				if err := store.AppendSwitch(record); err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+			}
+
+			all, err := store.History(0)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(all) != MaxHistory {
+				t.Fatalf("expected %d records, got %d", MaxHistory, len(all))
+			}
+			if all[len(all)-1].StartedAt.Unix() != 5 {
+				t.Errorf("expected the oldest 5 records to be dropped, oldest kept is %d", all[len(all)-1].StartedAt.Unix())
+			}
+
+			recent, err := store.History(2)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(recent) != 2 || recent[0].StartedAt.Unix() != MaxHistory+4 {
+				t.Errorf("expected the 2 newest records first, got %+v", recent)
+			}
+		})
+	}
+}
+
+func TestBoltStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+
+	store, err := OpenBolt(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	store.SaveActiveModels([]string{"model-b"})
+	store.AppendSwitch(models.SwitchRecord{ModelID: "model-b", Success: true})
+	store.Close()
+
+	store, err = OpenBolt(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	st, err := store.Load()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(st.ActiveModels) != 1 || st.ActiveModels[0] != "model-b" {
+		t.Errorf("expected active models to survive reopening, got %v", st.ActiveModels)
+	}
+
+	history, _ := store.History(0)
+	if len(history) != 1 || history[0].ModelID != "model-b" {
+		t.Errorf("expected history to survive reopening, got %+v", history)
+	}
+}
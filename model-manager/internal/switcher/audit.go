@@ -0,0 +1,141 @@
+package switcher
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/zheng/homeGPT/internal/audit"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// ActorSystem is the audit log actor of switches and corrections the manager
+// makes on its own, such as queued switches and state restored on startup
+const ActorSystem = "system"
+
+// actorContextKey carries who asked for a switch
+type actorContextKey struct{}
+
+// outcomeContextKey carries the switchOutcome of the switch running under a context
+type outcomeContextKey struct{}
+
+// switchOutcome collects what happened during a switch beyond its error
+type switchOutcome struct {
+	rolledBack atomic.Bool
+}
+
+// WithAuditLog records finished switches and resync corrections in the given log
+func WithAuditLog(l *audit.Log) Option {
+	return func(s *Switcher) {
+		s.audit = l
+	}
+}
+
+// WithActor attributes switches started under ctx to actor, such as the name of
+// the API key a request authenticated with, in the audit log
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// actorFrom returns the actor carried by ctx, or ActorSystem
+func actorFrom(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorContextKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return ActorSystem
+}
+
+// reportRollback notes on the switch running under ctx that it undid its changes
+func reportRollback(ctx context.Context) {
+	if o, ok := ctx.Value(outcomeContextKey{}).(*switchOutcome); ok {
+		o.rolledBack.Store(true)
+	}
+}
+
+// auditSwitch records a finished switch in the audit log
+func (s *Switcher) auditSwitch(ctx context.Context, targetModelID string, from []string, started time.Time, outcome *switchOutcome, err error) {
+	now := time.Now()
+	entry := models.AuditEntry{
+		Time:            now,
+		Action:          models.AuditSwitch,
+		Actor:           actorFrom(ctx),
+		ModelID:         targetModelID,
+		From:            from,
+		DurationSeconds: now.Sub(started).Seconds(),
+		Success:         err == nil,
+		RolledBack:      outcome.rolledBack.Load(),
+		Priority:        priorityFrom(ctx),
+		Forced:          forced(ctx),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	s.appendAudit(entry)
+}
+
+// auditEvent records status changes made by resync, which found a model in a
+// different state than the switcher thought it was in
+func (s *Switcher) auditEvent(event models.StatusEvent) {
+	if event.Cause != models.CauseResync {
+		return
+	}
+	s.appendAudit(models.AuditEntry{
+		Time:      event.Timestamp,
+		Action:    models.AuditResync,
+		Actor:     ActorSystem,
+		ModelID:   event.ModelID,
+		OldStatus: event.OldStatus,
+		NewStatus: event.NewStatus,
+		Success:   true,
+	})
+}
+
+// auditSplitBrain records a model put to sleep to resolve a split brain
+func (s *Switcher) auditSplitBrain(modelID string, kept []string, err error) {
+	entry := models.AuditEntry{
+		Time:      time.Now(),
+		Action:    models.AuditSplitBrain,
+		Actor:     ActorSystem,
+		ModelID:   modelID,
+		From:      kept,
+		OldStatus: models.StatusActive,
+		Success:   err == nil,
+	}
+	if err == nil {
+		entry.NewStatus = models.StatusSleeping
+	} else {
+		entry.Error = err.Error()
+	}
+	s.appendAudit(entry)
+}
+
+// auditSleep records a model put to sleep by a schedule
+func (s *Switcher) auditSleep(ctx context.Context, modelID string, err error) {
+	entry := models.AuditEntry{
+		Time:      time.Now(),
+		Action:    models.AuditSleep,
+		Actor:     actorFrom(ctx),
+		ModelID:   modelID,
+		OldStatus: models.StatusActive,
+		Success:   err == nil,
+	}
+	if err == nil {
+		entry.NewStatus = models.StatusSleeping
+	} else {
+		entry.Error = err.Error()
+	}
+	s.appendAudit(entry)
+}
+
+// appendAudit writes an entry to the audit log, logging failures
+func (s *Switcher) appendAudit(entry models.AuditEntry) {
+	if err := s.audit.Append(entry); err != nil {
+		log.Printf("Warning: failed to write audit log: %v", err)
+	}
+}
+
+// AuditLog returns the audit log entries matching filter, newest first
+func (s *Switcher) AuditLog(filter audit.Filter) ([]models.AuditEntry, error) {
+	return s.audit.Query(filter)
+}
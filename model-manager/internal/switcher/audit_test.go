@@ -0,0 +1,121 @@
+package switcher
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/internal/audit"
+	"github.com/zheng/homeGPT/internal/system"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+func openTestAuditLog(t *testing.T) *audit.Log {
+	t.Helper()
+
+	l, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"), 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestAudit_RecordsSwitchesWithActor(t *testing.T) {
+	mockClient := newStatefulMock("vllm-b")
+	s := NewWithClient(stateTestConfig(), mockClient,
+		WithoutBackgroundTasks(),
+		WithAuditLog(openTestAuditLog(t)),
+		WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 128}),
+		WithMaxRetries(2),
+		WithHealthCheckInterval(10*time.Millisecond))
+
+	if err := s.SwitchModel(WithActor(context.Background(), "open-webui"), "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// A failed switch wakes model-b again
+	wakeUp := mockClient.WakeUpFunc
+	mockClient.WakeUpFunc = func(ctx context.Context, host string, port int) error {
+		if host == "vllm-a" {
+			return errors.New("wake failed")
+		}
+		return wakeUp(ctx, host, port)
+	}
+	if err := s.SwitchModel(context.Background(), "model-a"); err == nil {
+		t.Fatal("expected switch to model-a to fail")
+	}
+
+	entries, err := s.AuditLog(audit.Filter{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %+v", entries)
+	}
+
+	failed, ok := entries[0], entries[1]
+	if ok.Action != models.AuditSwitch || ok.Actor != "open-webui" || ok.ModelID != "model-b" ||
+		!ok.Success || len(ok.From) != 1 || ok.From[0] != "model-a" || ok.RolledBack {
+		t.Errorf("expected a successful switch from model-a to model-b by open-webui, got %+v", ok)
+	}
+	if failed.Actor != ActorSystem || failed.ModelID != "model-a" || failed.Success || !failed.RolledBack || failed.Error == "" {
+		t.Errorf("expected a rolled back switch to model-a by the system, got %+v", failed)
+	}
+}
+
+func TestAudit_RecordsJobActor(t *testing.T) {
+	s := NewWithClient(stateTestConfig(), newStatefulMock("vllm-b"),
+		WithoutBackgroundTasks(),
+		WithAuditLog(openTestAuditLog(t)),
+		WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 128}),
+		WithMaxRetries(2),
+		WithHealthCheckInterval(10*time.Millisecond))
+
+	job, err := s.StartSwitchJob(WithActor(context.Background(), "ops"), "model-b")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	finishedJob(t, s, job.ID)
+
+	entries, _ := s.AuditLog(audit.Filter{ModelID: "model-b"})
+	if len(entries) != 1 || entries[0].Actor != "ops" {
+		t.Errorf("expected the job's switch to be attributed to ops, got %+v", entries)
+	}
+}
+
+func TestAudit_RecordsResyncCorrections(t *testing.T) {
+	// model-b was woken out of band and doesn't fit alongside model-a
+	s := NewWithClient(stateTestConfig(), newStatefulMock(),
+		WithoutBackgroundTasks(),
+		WithAuditLog(openTestAuditLog(t)),
+		WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 128}))
+
+	s.resyncModels(context.Background())
+
+	entries, err := s.AuditLog(audit.Filter{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var corrected, evicted bool
+	for _, e := range entries {
+		switch {
+		case e.Action == models.AuditResync && e.ModelID == "model-b" &&
+			e.OldStatus == models.StatusSleeping && e.NewStatus == models.StatusActive:
+			corrected = true
+		case e.Action == models.AuditSplitBrain && e.ModelID == "model-b" && e.Success:
+			evicted = true
+		case e.Action == models.AuditResync && e.ModelID == "model-a":
+			t.Errorf("expected no correction for model-a, whose state was right, got %+v", e)
+		}
+	}
+	if !corrected {
+		t.Errorf("expected model-b found awake to be recorded, got %+v", entries)
+	}
+	if !evicted {
+		t.Errorf("expected model-b put to sleep for split brain to be recorded, got %+v", entries)
+	}
+}
@@ -0,0 +1,102 @@
+package switcher
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// BootstrapStep reports progress through the startup sequence
+type BootstrapStep struct {
+	Index   int    // 1-based position of the model in the load order
+	Total   int    // Number of models to load
+	ModelID string // Model the step applies to
+	Action  string // "loading", "loaded", "sleeping", "asleep", "waking", "ready"
+}
+
+// Bootstrap brings every non-disabled model up from cold. Models are loaded one
+// at a time (active models first) and, when any model starts asleep, each one is
+// put to sleep right after loading to free VRAM for the next; the active models
+// are then woken. This mirrors the startup sequence vLLM sleep mode needs on a
+// single set of GPUs. progress may be nil.
+func (s *Switcher) Bootstrap(ctx context.Context, progress func(BootstrapStep)) error {
+	s.switchLock.Lock()
+	defer s.switchLock.Unlock()
+
+	if progress == nil {
+		progress = func(BootstrapStep) {}
+	}
+
+	var active, sleeping []*models.Model
+	for i := range s.config.Models {
+		model := s.models[s.config.Models[i].ID]
+		switch model.StartupMode {
+		case models.StartupActive:
+			active = append(active, model)
+		case models.StartupSleep:
+			sleeping = append(sleeping, model)
+		}
+	}
+
+	order := append(append([]*models.Model{}, active...), sleeping...)
+	// With nothing to cache, active models can stay awake after loading
+	cacheAll := len(sleeping) > 0
+
+	for i, model := range order {
+		step := BootstrapStep{Index: i + 1, Total: len(order), ModelID: model.ID}
+
+		step.Action = "loading"
+		progress(step)
+		if err := s.loadModel(ctx, model); err != nil {
+			return fmt.Errorf("failed to load model %s: %w", model.ID, err)
+		}
+		step.Action = "loaded"
+		progress(step)
+
+		if !cacheAll {
+			continue
+		}
+
+		step.Action = "sleeping"
+		progress(step)
+		if err := s.sleepModel(ctx, model.ID); err != nil {
+			return fmt.Errorf("failed to sleep model %s: %w", model.ID, err)
+		}
+		step.Action = "asleep"
+		progress(step)
+	}
+
+	for i, model := range active {
+		if cacheAll {
+			step := BootstrapStep{Index: i + 1, Total: len(active), ModelID: model.ID, Action: "waking"}
+			progress(step)
+			if err := s.activateModel(ctx, model.ID); err != nil {
+				return fmt.Errorf("failed to wake model %s: %w", model.ID, err)
+			}
+		}
+		progress(BootstrapStep{Index: i + 1, Total: len(active), ModelID: model.ID, Action: "ready"})
+	}
+
+	s.mapMu.Lock()
+	if len(active) > 0 {
+		s.activeModel = active[0].ID
+	}
+	s.mapMu.Unlock()
+
+	log.Printf("Bootstrap complete: %d models loaded, %d active", len(order), len(active))
+	return nil
+}
+
+// loadModel starts a model's container if a runtime is available, then waits for
+// vLLM to finish loading it
+func (s *Switcher) loadModel(ctx context.Context, model *models.Model) error {
+	if s.containers != nil {
+		return s.startContainer(ctx, model)
+	}
+
+	// Containers are started externally; just wait for the server to come up
+	s.mark(ctx, model, models.StatusSwitching)
+	return s.waitHealthy(ctx, model)
+}
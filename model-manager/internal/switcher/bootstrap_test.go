@@ -0,0 +1,138 @@
+package switcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/internal/runtime"
+	"github.com/zheng/homeGPT/internal/system"
+	"github.com/zheng/homeGPT/internal/vllm"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+func TestBootstrap_LoadsSleepsAndWakes(t *testing.T) {
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, GPUMemoryGB: 40, StartupMode: models.StartupSleep},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, GPUMemoryGB: 40, StartupMode: models.StartupActive},
+			{ID: "model-c", ContainerName: "vllm-c", Port: 8000, StartupMode: models.StartupDisabled},
+		},
+	}
+
+	mockClient := vllm.NewMockClient()
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		return true, nil
+	}
+	rt := runtime.NewFakeRuntime(map[string]runtime.State{
+		"vllm-a": runtime.StateCreated,
+		"vllm-b": runtime.StateCreated,
+		"vllm-c": runtime.StateCreated,
+	})
+
+	s := NewWithClient(cfg, mockClient,
+		WithContainerRuntime(rt),
+		WithoutBackgroundTasks(),
+		WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 128}),
+		WithMaxRetries(2),
+		WithHealthCheckInterval(10*time.Millisecond))
+
+	var steps []string
+	err := s.Bootstrap(context.Background(), func(step BootstrapStep) {
+		steps = append(steps, fmt.Sprintf("%d/%d %s %s", step.Index, step.Total, step.ModelID, step.Action))
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []string{
+		"1/2 model-b loading", "1/2 model-b loaded", "1/2 model-b sleeping", "1/2 model-b asleep",
+		"2/2 model-a loading", "2/2 model-a loaded", "2/2 model-a sleeping", "2/2 model-a asleep",
+		"1/1 model-b waking", "1/1 model-b ready",
+	}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Errorf("unexpected steps:\n got %v\nwant %v", steps, expected)
+	}
+
+	// Disabled models are never started
+	if !reflect.DeepEqual(rt.StartCalls, []string{"vllm-b", "vllm-a"}) {
+		t.Errorf("expected vllm-b then vllm-a started, got %v", rt.StartCalls)
+	}
+
+	// Sleep level comes from determineSleepLevel, not a fixed level 1
+	for _, call := range mockClient.SleepCalls {
+		if call.Level != 1 {
+			t.Errorf("expected level 1 with plenty of RAM, got %d for %s", call.Level, call.Host)
+		}
+	}
+
+	if len(mockClient.WakeUpCalls) != 1 || mockClient.WakeUpCalls[0].Host != "vllm-b" {
+		t.Errorf("expected only vllm-b woken, got %+v", mockClient.WakeUpCalls)
+	}
+
+	if s.models["model-b"].GetStatus() != models.StatusActive {
+		t.Errorf("expected model-b active, got %s", s.models["model-b"].GetStatus())
+	}
+	if s.models["model-a"].GetStatus() != models.StatusSleeping {
+		t.Errorf("expected model-a sleeping, got %s", s.models["model-a"].GetStatus())
+	}
+	if s.GetModels().ActiveModel != "model-b" {
+		t.Errorf("expected model-b as active model, got '%s'", s.GetModels().ActiveModel)
+	}
+}
+
+func TestBootstrap_NoSleepModelsStaysAwake(t *testing.T) {
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive},
+		},
+	}
+
+	mockClient := vllm.NewMockClient()
+	s := NewWithClient(cfg, mockClient, WithoutBackgroundTasks(), WithMaxRetries(2), WithHealthCheckInterval(10*time.Millisecond))
+
+	if err := s.Bootstrap(context.Background(), nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(mockClient.SleepCalls) != 0 || len(mockClient.WakeUpCalls) != 0 {
+		t.Errorf("expected no sleep/wake calls, got %d/%d", len(mockClient.SleepCalls), len(mockClient.WakeUpCalls))
+	}
+	if s.models["model-a"].GetStatus() != models.StatusActive {
+		t.Errorf("expected model-a active, got %s", s.models["model-a"].GetStatus())
+	}
+}
+
+func TestBootstrap_LoadFailure(t *testing.T) {
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupSleep},
+		},
+	}
+
+	mockClient := vllm.NewMockClient()
+	mockClient.HealthFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		if host == "vllm-b" {
+			return false, errors.New("connection refused")
+		}
+		return true, nil
+	}
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		return true, nil
+	}
+
+	s := NewWithClient(cfg, mockClient, WithoutBackgroundTasks(), WithMaxRetries(2), WithHealthCheckInterval(10*time.Millisecond))
+
+	err := s.Bootstrap(context.Background(), nil)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	if s.models["model-b"].GetStatus() != models.StatusError {
+		t.Errorf("expected model-b in error, got %s", s.models["model-b"].GetStatus())
+	}
+}
@@ -0,0 +1,86 @@
+package switcher
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// runningSwitch is the switch holding switchLock
+type runningSwitch struct {
+	modelID string
+	cancel  context.CancelCauseFunc
+}
+
+// beginSwitch registers the switch that just took switchLock so CancelSwitch can
+// abort it, and returns its context and a func to call once it is done
+func (s *Switcher) beginSwitch(ctx context.Context, modelID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	run := &runningSwitch{modelID: modelID, cancel: cancel}
+
+	s.runningMu.Lock()
+	s.running = run
+	s.runningMu.Unlock()
+
+	return ctx, func() {
+		s.runningMu.Lock()
+		if s.running == run {
+			s.running = nil
+		}
+		s.runningMu.Unlock()
+		cancel(nil)
+	}
+}
+
+// CancelSwitch aborts the switch in progress and returns its target model. The
+// switch stops waiting for the target, puts it back to sleep, wakes the models
+// it evicted and releases the switch lock; callers waiting on it get an error
+// matching ErrSwitchCanceled.
+func (s *Switcher) CancelSwitch() (string, error) {
+	s.runningMu.Lock()
+	defer s.runningMu.Unlock()
+
+	if s.running == nil {
+		return "", ErrNoSwitchInFlight
+	}
+	log.Printf("Canceling switch to %s", s.running.modelID)
+	s.running.cancel(ErrSwitchCanceled)
+	return s.running.modelID, nil
+}
+
+// CancelSwitchJob aborts an asynchronous switch, whether it is running or still
+// waiting for another switch to finish
+func (s *Switcher) CancelSwitchJob(id string) (models.SwitchJob, error) {
+	j, ok := s.jobs.get(id)
+	if !ok {
+		return models.SwitchJob{}, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	if job := j.snapshot(); job.FinishedAt != nil {
+		return job, fmt.Errorf("%w: %s", ErrJobFinished, id)
+	}
+
+	log.Printf("Canceling switch job %s to %s", id, j.job.ModelID)
+	j.cancel(ErrSwitchCanceled)
+	return j.snapshot(), nil
+}
+
+// abortSwitch rolls back a switch whose context was canceled: the target is put
+// back to sleep if it was being woken, and the models evicted for it are woken
+// again. The rollback itself runs to completion.
+func (s *Switcher) abortSwitch(ctx context.Context, targetModelID string, woke bool, evicted []string) error {
+	cause := context.Cause(ctx)
+	log.Printf("Switch to %s aborted (%v), rolling back", targetModelID, cause)
+	ctx = context.WithoutCancel(ctx)
+
+	if woke {
+		reportRollback(ctx)
+		if err := s.releaseModel(ctx, targetModelID); err != nil {
+			log.Printf("Warning: failed to put %s back to sleep: %v", targetModelID, err)
+		}
+	}
+	s.reactivateModels(ctx, evicted)
+
+	return fmt.Errorf("switch to %s aborted: %w", targetModelID, cause)
+}
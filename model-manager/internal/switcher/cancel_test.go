@@ -0,0 +1,163 @@
+package switcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/internal/system"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// setupHungSwitcher returns a switcher where model-b wakes up but never reports
+// healthy, and a channel that receives once its health polling has started
+func setupHungSwitcher(t *testing.T) (*Switcher, <-chan struct{}) {
+	t.Helper()
+
+	polling := make(chan struct{})
+	var once sync.Once
+	mockClient := newStatefulMock("vllm-b")
+	mockClient.HealthFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		if host == "vllm-b" {
+			once.Do(func() { close(polling) })
+			return false, nil
+		}
+		return true, nil
+	}
+
+	s := NewWithClient(stateTestConfig(), mockClient,
+		WithoutBackgroundTasks(),
+		WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 128}),
+		WithMaxRetries(450),
+		WithHealthCheckInterval(time.Minute))
+	return s, polling
+}
+
+func TestCancelSwitch_AbortsHealthPolling(t *testing.T) {
+	s, polling := setupHungSwitcher(t)
+
+	result := make(chan error, 1)
+	go func() { result <- s.SwitchModel(context.Background(), "model-b") }()
+	<-polling
+
+	modelID, err := s.CancelSwitch()
+	if err != nil || modelID != "model-b" {
+		t.Fatalf("expected to cancel the switch to model-b, got %q, %v", modelID, err)
+	}
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrSwitchCanceled) {
+			t.Errorf("expected ErrSwitchCanceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the switch to stop polling right away")
+	}
+
+	resp := s.GetModels()
+	if resp.ActiveModel != "model-a" || s.models["model-a"].GetStatus() != models.StatusActive {
+		t.Errorf("expected model-a to be restored, got active %s (%s)", resp.ActiveModel, s.models["model-a"].GetStatus())
+	}
+	if status := s.models["model-b"].GetStatus(); status != models.StatusSleeping {
+		t.Errorf("expected model-b to be put back to sleep, got %s", status)
+	}
+
+	if !s.switchLock.TryLock() {
+		t.Fatal("expected the switch lock to be released")
+	}
+	s.switchLock.Unlock()
+
+	if _, err := s.CancelSwitch(); !errors.Is(err, ErrNoSwitchInFlight) {
+		t.Errorf("expected ErrNoSwitchInFlight once the switch finished, got %v", err)
+	}
+}
+
+func TestCancelSwitch_CanceledRequestContext(t *testing.T) {
+	s, polling := setupHungSwitcher(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- s.SwitchModel(ctx, "model-b") }()
+	<-polling
+	cancel()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the switch to stop when its caller went away")
+	}
+
+	if status := s.models["model-a"].GetStatus(); status != models.StatusActive {
+		t.Errorf("expected model-a to be restored, got %s", status)
+	}
+}
+
+func TestCancelSwitchJob_Running(t *testing.T) {
+	s, polling := setupHungSwitcher(t)
+
+	job, err := s.StartSwitchJob(context.Background(), "model-b")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	<-polling
+
+	if _, err := s.CancelSwitchJob(job.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	got := finishedJob(t, s, job.ID)
+	if got.Phase != models.PhaseCanceled {
+		t.Errorf("expected job to be canceled, got %s (%s)", got.Phase, got.Error)
+	}
+	if _, err := s.CancelSwitchJob(job.ID); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("expected ErrJobFinished for a finished job, got %v", err)
+	}
+}
+
+func TestCancelSwitchJob_Queued(t *testing.T) {
+	mockClient := newStatefulMock("vllm-b")
+	s := NewWithClient(stateTestConfig(), mockClient, WithoutBackgroundTasks())
+
+	// Hold the lock as if another switch were running
+	s.switchLock.Lock()
+	job, err := s.StartSwitchJob(context.Background(), "model-b")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := s.CancelSwitchJob(job.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	s.switchLock.Unlock()
+
+	if got := finishedJob(t, s, job.ID); got.Phase != models.PhaseCanceled {
+		t.Errorf("expected job to be canceled, got %s", got.Phase)
+	}
+	if len(mockClient.SleepCalls) != 0 || len(mockClient.WakeUpCalls) != 0 {
+		t.Errorf("expected a job canceled in the queue never to start, got %d sleep and %d wake_up calls",
+			len(mockClient.SleepCalls), len(mockClient.WakeUpCalls))
+	}
+}
+
+func TestCancelSwitchJob_NotFound(t *testing.T) {
+	s := NewWithClient(stateTestConfig(), newStatefulMock(), WithoutBackgroundTasks())
+
+	if _, err := s.CancelSwitchJob("unknown"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound, got %v", err)
+	}
+}
+
+// finishedJob waits for a switch job to finish and returns it
+func finishedJob(t *testing.T, s *Switcher, id string) models.SwitchJob {
+	t.Helper()
+	waitFor(t, func() bool {
+		job, _ := s.GetSwitchJob(id)
+		return job.FinishedAt != nil
+	})
+	job, _ := s.GetSwitchJob(id)
+	return job
+}
@@ -0,0 +1,138 @@
+package switcher
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/zheng/homeGPT/internal/runtime"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// containerStopTimeout is how long a container gets to shut down before it is killed
+const containerStopTimeout = 30 * time.Second
+
+// WithContainerRuntime lets the switcher start containers for models with
+// startup_mode: disabled on demand and stop them again when they are evicted
+func WithContainerRuntime(rt runtime.ContainerRuntime) Option {
+	return func(s *Switcher) {
+		s.containers = rt
+	}
+}
+
+// startsOnDemand reports whether a model's container is started and stopped by
+// the switcher instead of being kept running asleep
+func (s *Switcher) startsOnDemand(model *models.Model) bool {
+	return s.containers != nil && model.StartupMode == models.StartupDisabled
+}
+
+// bringUpModel makes a model active, starting its container first if it isn't running
+func (s *Switcher) bringUpModel(ctx context.Context, modelID string) error {
+	s.mapMu.RLock()
+	model := s.models[modelID]
+	s.mapMu.RUnlock()
+
+	if s.startsOnDemand(model) && model.GetStatus() == models.StatusDisabled {
+		return s.startContainer(ctx, model)
+	}
+	return s.activateModel(ctx, modelID)
+}
+
+// releaseModel frees a model's GPU memory: containers started on demand are
+// stopped, every other model is put to sleep
+func (s *Switcher) releaseModel(ctx context.Context, modelID string) error {
+	s.mapMu.RLock()
+	model := s.models[modelID]
+	s.mapMu.RUnlock()
+
+	if s.startsOnDemand(model) {
+		return s.stopContainer(ctx, model)
+	}
+	return s.sleepModel(ctx, modelID)
+}
+
+// startContainer creates (if needed) and starts a model's container, then waits
+// for vLLM inside it to load the model and report healthy
+func (s *Switcher) startContainer(ctx context.Context, model *models.Model) error {
+	s.mark(ctx, model, models.StatusSwitching)
+
+	state, err := s.containers.State(ctx, model.ContainerName)
+	if err != nil {
+		model.MarkError()
+		return fmt.Errorf("failed to inspect container %s: %w", model.ContainerName, err)
+	}
+
+	if state == runtime.StateMissing {
+		if model.Container == nil {
+			model.MarkError()
+			return fmt.Errorf("container %s does not exist and model %s has no container config", model.ContainerName, model.ID)
+		}
+
+		log.Printf("Creating container %s for model %s", model.ContainerName, model.ID)
+		spec := runtime.ContainerSpec{
+			Name:    model.ContainerName,
+			Image:   model.Container.Image,
+			Command: model.Container.Command,
+			Env:     model.Container.Env,
+			Volumes: model.Container.Volumes,
+			Network: model.Container.Network,
+			GPUs:    model.GPUs,
+		}
+		if err := s.containers.Create(ctx, spec); err != nil {
+			model.MarkError()
+			return fmt.Errorf("failed to create container %s: %w", model.ContainerName, err)
+		}
+	}
+
+	if state != runtime.StateRunning {
+		log.Printf("Starting container %s for model %s", model.ContainerName, model.ID)
+		if err := s.containers.Start(ctx, model.ContainerName); err != nil {
+			model.MarkError()
+			return fmt.Errorf("failed to start container %s: %w", model.ContainerName, err)
+		}
+	}
+
+	// A freshly started vLLM server loads its weights awake
+	return s.waitHealthy(ctx, model)
+}
+
+// stopContainer stops a container that was started on demand
+func (s *Switcher) stopContainer(ctx context.Context, model *models.Model) error {
+	s.mark(ctx, model, models.StatusSwitching)
+
+	log.Printf("Stopping container %s for model %s", model.ContainerName, model.ID)
+	if err := s.containers.Stop(ctx, model.ContainerName, containerStopTimeout); err != nil {
+		model.MarkError()
+		return fmt.Errorf("failed to stop container %s: %w", model.ContainerName, err)
+	}
+
+	s.mark(ctx, model, models.StatusDisabled)
+	log.Printf("Container %s for model %s stopped", model.ContainerName, model.ID)
+	return nil
+}
+
+// containerRunning reports whether a model started on demand still has its
+// container running
+func (s *Switcher) containerRunning(ctx context.Context, model *models.Model) bool {
+	if !s.startsOnDemand(model) {
+		return false
+	}
+	state, err := s.containers.State(ctx, model.ContainerName)
+	return err == nil && state == runtime.StateRunning
+}
+
+// resyncContainer reports whether an on-demand model's container is running,
+// marking the model disabled if it is not
+func (s *Switcher) resyncContainer(ctx context.Context, model *models.Model) (bool, error) {
+	state, err := s.containers.State(ctx, model.ContainerName)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect container %s: %w", model.ContainerName, err)
+	}
+
+	if state != runtime.StateRunning {
+		s.mark(ctx, model, models.StatusDisabled)
+		return false, nil
+	}
+	return true, nil
+}
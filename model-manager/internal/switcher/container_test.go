@@ -0,0 +1,207 @@
+package switcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/internal/runtime"
+	"github.com/zheng/homeGPT/internal/vllm"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// setupContainerSwitcher creates a switcher where model-a is active, model-b is
+// disabled with a stopped container and model-c is disabled with no container
+func setupContainerSwitcher(t *testing.T) (*Switcher, *vllm.MockClient, *runtime.FakeRuntime) {
+	t.Helper()
+
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupDisabled},
+			{ID: "model-c", ContainerName: "vllm-c", Port: 8000, StartupMode: models.StartupDisabled, GPUs: []int{1},
+				Container: &models.ContainerConfig{Image: "vllm/vllm-openai:v0.11.1", Network: "homegpt-network"}},
+		},
+	}
+
+	mockClient := vllm.NewMockClient()
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		for _, call := range mockClient.SleepCalls {
+			if call.Host == host {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	rt := runtime.NewFakeRuntime(map[string]runtime.State{
+		"vllm-a": runtime.StateRunning,
+		"vllm-b": runtime.StateExited,
+	})
+
+	s := NewWithClient(cfg, mockClient, WithContainerRuntime(rt), WithMaxRetries(2), WithHealthCheckInterval(10*time.Millisecond))
+	s.WaitForInit()
+
+	return s, mockClient, rt
+}
+
+func TestSwitchModel_StartsStoppedContainer(t *testing.T) {
+	s, mockClient, rt := setupContainerSwitcher(t)
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(rt.StartCalls) != 1 || rt.StartCalls[0] != "vllm-b" {
+		t.Errorf("expected vllm-b to be started, got %v", rt.StartCalls)
+	}
+	if len(rt.CreateCalls) != 0 {
+		t.Errorf("expected no containers created, got %d", len(rt.CreateCalls))
+	}
+
+	// A fresh container comes up awake, so no wake_up is needed
+	for _, call := range mockClient.WakeUpCalls {
+		if call.Host == "vllm-b" {
+			t.Error("expected no wake_up call for a freshly started container")
+		}
+	}
+
+	if s.models["model-b"].GetStatus() != models.StatusActive {
+		t.Errorf("expected model-b active, got %s", s.models["model-b"].GetStatus())
+	}
+	if s.models["model-a"].GetStatus() != models.StatusSleeping {
+		t.Errorf("expected model-a sleeping, got %s", s.models["model-a"].GetStatus())
+	}
+}
+
+func TestSwitchModel_CreatesMissingContainer(t *testing.T) {
+	s, _, rt := setupContainerSwitcher(t)
+
+	if err := s.SwitchModel(context.Background(), "model-c"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(rt.CreateCalls) != 1 {
+		t.Fatalf("expected 1 container created, got %d", len(rt.CreateCalls))
+	}
+
+	spec := rt.CreateCalls[0]
+	if spec.Name != "vllm-c" || spec.Image != "vllm/vllm-openai:v0.11.1" || len(spec.GPUs) != 1 || spec.GPUs[0] != 1 {
+		t.Errorf("unexpected container spec: %+v", spec)
+	}
+
+	if state, _ := rt.State(context.Background(), "vllm-c"); state != runtime.StateRunning {
+		t.Errorf("expected vllm-c running, got %s", state)
+	}
+}
+
+func TestSwitchModel_StopsOnDemandContainerWhenEvicted(t *testing.T) {
+	s, mockClient, rt := setupContainerSwitcher(t)
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("failed to start model-b: %v", err)
+	}
+
+	if err := s.SwitchModel(context.Background(), "model-a"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(rt.StopCalls) != 1 || rt.StopCalls[0] != "vllm-b" {
+		t.Errorf("expected vllm-b to be stopped, got %v", rt.StopCalls)
+	}
+	for _, call := range mockClient.SleepCalls {
+		if call.Host == "vllm-b" {
+			t.Error("expected on-demand model to be stopped, not slept")
+		}
+	}
+
+	if s.models["model-b"].GetStatus() != models.StatusDisabled {
+		t.Errorf("expected model-b disabled, got %s", s.models["model-b"].GetStatus())
+	}
+}
+
+func TestSwitchModel_StartContainerFails(t *testing.T) {
+	s, _, rt := setupContainerSwitcher(t)
+	rt.StartFunc = func(ctx context.Context, name string) error {
+		return errors.New("could not select device driver")
+	}
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	// model-a is brought back after the failed switch
+	if s.models["model-a"].GetStatus() != models.StatusActive {
+		t.Errorf("expected model-a to be reactivated, got %s", s.models["model-a"].GetStatus())
+	}
+	if s.GetModels().ActiveModel != "model-a" {
+		t.Errorf("expected model-a to remain active model, got '%s'", s.GetModels().ActiveModel)
+	}
+}
+
+func TestEnsureActive_StartsDisabledModel(t *testing.T) {
+	s, _, _ := setupContainerSwitcher(t)
+
+	model, err := s.EnsureActive(context.Background(), "model-b")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if model.GetStatus() != models.StatusActive {
+		t.Errorf("expected model-b active, got %s", model.GetStatus())
+	}
+}
+
+func TestResyncModels_OnDemandContainerStoppedOutOfBand(t *testing.T) {
+	s, _, rt := setupContainerSwitcher(t)
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("failed to start model-b: %v", err)
+	}
+
+	rt.Stop(context.Background(), "vllm-b", time.Second)
+	s.resyncModels(context.Background())
+
+	if s.models["model-b"].GetStatus() != models.StatusDisabled {
+		t.Errorf("expected model-b disabled after its container stopped, got %s", s.models["model-b"].GetStatus())
+	}
+}
+
+func TestSwitchModel_WaitsForStartingContainerBehindOpenCircuit(t *testing.T) {
+	cfg := &models.Config{
+		CircuitBreaker: models.BreakerConfig{FailureThreshold: 2, OpenSeconds: 1},
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupDisabled},
+		},
+	}
+
+	// vLLM refuses connections while it loads the model
+	start := time.Now()
+	mockClient := newStatefulMock()
+	mockClient.HealthFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		if host == "vllm-b" && time.Since(start) < 200*time.Millisecond {
+			return false, errors.New("connection refused")
+		}
+		return true, nil
+	}
+
+	rt := runtime.NewFakeRuntime(map[string]runtime.State{
+		"vllm-a": runtime.StateRunning,
+		"vllm-b": runtime.StateExited,
+	})
+
+	s := NewWithClient(cfg, mockClient,
+		WithoutBackgroundTasks(),
+		WithCircuitBreaker(),
+		WithContainerRuntime(rt),
+		WithMaxRetries(500),
+		WithHealthCheckInterval(10*time.Millisecond))
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected the started container to come up, got %v", err)
+	}
+	if state := s.GetModels().Circuits["model-b"].State; state != models.CircuitClosed {
+		t.Errorf("expected model-b's circuit to close once it answered, got %s", state)
+	}
+}
@@ -0,0 +1,196 @@
+package switcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+const defaultThrashWindow = 10 * time.Minute
+
+// Reasons a switch is held back by a CooldownError
+const (
+	CooldownMinResidency = "min_residency"
+	CooldownThrashing    = "thrashing"
+)
+
+// ErrCooldown is matched by a CooldownError
+var ErrCooldown = errors.New("switch held back by cooldown")
+
+// CooldownError is returned when a switch is held back, either because a model
+// it would put to sleep hasn't been awake for its min_residency yet, or because
+// recent switches tripped the thrash detector
+type CooldownError struct {
+	Reason     string        // CooldownMinResidency or CooldownThrashing
+	ModelID    string        // Model within its min_residency (CooldownMinResidency only)
+	RetryAfter time.Duration // Time left before the switch is allowed
+}
+
+func (e *CooldownError) Error() string {
+	if e.Reason == CooldownMinResidency {
+		return fmt.Sprintf("model %s must stay active for another %s (min_residency)",
+			e.ModelID, e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("switching is backing off for another %s after thrashing",
+		e.RetryAfter.Round(time.Second))
+}
+
+// Is lets errors.Is match ErrCooldown
+func (e *CooldownError) Is(target error) bool {
+	return target == ErrCooldown
+}
+
+// checkResidency returns a CooldownError if one of the given models hasn't been
+// active for its min_residency yet, unless the switch is forced
+func (s *Switcher) checkResidency(ctx context.Context, evict []string) error {
+	now := time.Now()
+	for _, id := range evict {
+		s.mapMu.RLock()
+		model, ok := s.models[id]
+		s.mapMu.RUnlock()
+		if !ok {
+			continue
+		}
+
+		remaining := model.ResidencyRemaining(now)
+		if remaining <= 0 {
+			continue
+		}
+		if forced(ctx) {
+			log.Printf("Forcing %s to sleep %s before its min_residency ends", id, remaining.Round(time.Second))
+			continue
+		}
+		return &CooldownError{Reason: CooldownMinResidency, ModelID: id, RetryAfter: remaining}
+	}
+	return nil
+}
+
+// thrashDetector counts switches over a sliding window and, once there are too
+// many, holds further switches back for a while
+type thrashDetector struct {
+	mu           sync.Mutex
+	cfg          models.ThrashConfig
+	switches     []time.Time // Oldest first, within the window
+	backoffUntil time.Time
+}
+
+// configure applies new settings, keeping the switches already counted
+func (d *thrashDetector) configure(cfg models.ThrashConfig) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cfg = cfg
+}
+
+// window returns the sliding window switches are counted over; callers hold mu
+func (d *thrashDetector) window() time.Duration {
+	if d.cfg.WindowSeconds > 0 {
+		return time.Duration(d.cfg.WindowSeconds) * time.Second
+	}
+	return defaultThrashWindow
+}
+
+// record counts a switch and reports whether it tripped the detector, along
+// with the number of switches in the window
+func (d *thrashDetector) record(now time.Time) (bool, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.cfg.MaxSwitches <= 0 {
+		return false, 0
+	}
+
+	cutoff := now.Add(-d.window())
+	kept := d.switches[:0]
+	for _, t := range d.switches {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	d.switches = append(kept, now)
+
+	count := len(d.switches)
+	if count <= d.cfg.MaxSwitches {
+		return false, count
+	}
+
+	// Start counting afresh, so the next alert takes another run of switches
+	d.switches = nil
+	d.backoffUntil = now.Add(time.Duration(d.cfg.BackoffSeconds) * time.Second)
+	return true, count
+}
+
+// alert describes count switches tripping the detector, and the backoff that follows
+func (d *thrashDetector) alert(count int) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	message := fmt.Sprintf("%d switches within %s exceed the limit of %d", count, d.window(), d.cfg.MaxSwitches)
+	if d.cfg.BackoffSeconds > 0 {
+		message += fmt.Sprintf("; holding switches back for %ds", d.cfg.BackoffSeconds)
+	}
+	return message
+}
+
+// backoffRemaining returns how long switches are still held back, or 0
+func (d *thrashDetector) backoffRemaining(now time.Time) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return max(d.backoffUntil.Sub(now), 0)
+}
+
+// checkThrashing returns a CooldownError while switches are backing off after
+// thrashing, unless the switch is forced
+func (s *Switcher) checkThrashing(ctx context.Context) error {
+	remaining := s.thrash.backoffRemaining(time.Now())
+	if remaining <= 0 || forced(ctx) {
+		return nil
+	}
+	return &CooldownError{Reason: CooldownThrashing, RetryAfter: remaining}
+}
+
+// recordSwitchDone feeds a completed switch to the thrash detector, raising an
+// alert when it trips
+func (s *Switcher) recordSwitchDone(targetModelID string) {
+	tripped, count := s.thrash.record(time.Now())
+	if !tripped {
+		return
+	}
+
+	message := s.thrash.alert(count)
+	log.Printf("Warning: thrashing: %s", message)
+	s.metrics.Thrashing()
+	s.events.Publish(models.StatusEvent{
+		ModelID:   targetModelID,
+		OldStatus: models.StatusActive,
+		NewStatus: models.StatusActive,
+		Cause:     models.CauseThrashing,
+		Message:   message,
+		Timestamp: time.Now(),
+	})
+}
+
+// holdQueue stops queued requests from starting switches for d, then drains the
+// queue again. Their requests keep waiting, up to the queue's max wait.
+func (s *Switcher) holdQueue(d time.Duration) {
+	until := time.Now().Add(d)
+
+	s.stateMu.Lock()
+	if until.After(s.queueHeldUntil) {
+		s.queueHeldUntil = until
+	}
+	s.stateMu.Unlock()
+
+	time.AfterFunc(d, s.drainQueue)
+}
+
+// queueHeld reports whether queued requests may not start switches yet
+func (s *Switcher) queueHeld() bool {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	return time.Now().Before(s.queueHeldUntil)
+}
@@ -0,0 +1,204 @@
+package switcher
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/internal/audit"
+	"github.com/zheng/homeGPT/internal/metrics"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+func TestSwitchModel_HeldByMinResidency(t *testing.T) {
+	cfg := stateTestConfig()
+	cfg.Models[1].MinResidency = time.Hour
+	s := newStatefulSwitcher(t, cfg, newStatefulMock("vllm-b"))
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err := s.SwitchModel(context.Background(), "model-a")
+	var cooldownErr *CooldownError
+	if !errors.As(err, &cooldownErr) || !errors.Is(err, ErrCooldown) {
+		t.Fatalf("expected a CooldownError, got %v", err)
+	}
+	if cooldownErr.Reason != CooldownMinResidency || cooldownErr.ModelID != "model-b" {
+		t.Errorf("expected model-b held by min_residency, got %+v", cooldownErr)
+	}
+	if cooldownErr.RetryAfter <= 59*time.Minute || cooldownErr.RetryAfter > time.Hour {
+		t.Errorf("expected about an hour left, got %s", cooldownErr.RetryAfter)
+	}
+	if s.GetModels().ActiveModel != "model-b" {
+		t.Errorf("expected model-b to stay active, got %s", s.GetModels().ActiveModel)
+	}
+
+	// An admin can force the switch anyway
+	if err := s.SwitchModel(WithForce(context.Background()), "model-a"); err != nil {
+		t.Fatalf("expected a forced switch to succeed, got %v", err)
+	}
+	if s.GetModels().ActiveModel != "model-a" {
+		t.Errorf("expected model-a to be active, got %s", s.GetModels().ActiveModel)
+	}
+}
+
+func TestStartSwitchJob_DeferredUntilResidencyEnds(t *testing.T) {
+	cfg := stateTestConfig()
+	cfg.Models[1].MinResidency = 100 * time.Millisecond
+	s := newStatefulSwitcher(t, cfg, newStatefulMock("vllm-b"))
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	job, err := s.StartSwitchJob(context.Background(), "model-a")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	waitFor(t, func() bool {
+		job, _ := s.GetSwitchJob(job.ID)
+		return job.Phase == models.PhaseDeferred
+	})
+
+	waitFor(t, func() bool {
+		job, _ := s.GetSwitchJob(job.ID)
+		return job.Phase == models.PhaseDone
+	})
+	if s.GetModels().ActiveModel != "model-a" {
+		t.Errorf("expected model-a to be active once the residency ended, got %s", s.GetModels().ActiveModel)
+	}
+}
+
+func TestStartSwitchJob_DeferralRecordsNoFailures(t *testing.T) {
+	m := metrics.New()
+	cfg := stateTestConfig()
+	cfg.Models[1].MinResidency = 100 * time.Millisecond
+	s := newStatefulSwitcher(t, cfg, newStatefulMock("vllm-b"), WithMetrics(m), WithAuditLog(openTestAuditLog(t)))
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	job, err := s.StartSwitchJob(context.Background(), "model-a")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	waitFor(t, func() bool {
+		job, _ := s.GetSwitchJob(job.ID)
+		return job.Phase == models.PhaseDone
+	})
+
+	// Only the two switches that ran are recorded, both successful
+	history, err := s.SwitchHistory(0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(history) != 2 || !history[0].Success || !history[1].Success {
+		t.Errorf("expected 2 successful switches in the history, got %+v", history)
+	}
+	entries, err := s.AuditLog(audit.Filter{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(entries) != 2 || !entries[0].Success || !entries[1].Success {
+		t.Errorf("expected 2 successful switches in the audit log, got %+v", entries)
+	}
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if body := w.Body.String(); strings.Contains(body, `result="failure"`) {
+		t.Errorf("expected no failed switches in the metrics, got:\n%s", body)
+	}
+}
+
+func TestSwitchModel_ThrashingBacksOff(t *testing.T) {
+	cfg := stateTestConfig()
+	cfg.Thrash = models.ThrashConfig{MaxSwitches: 2, BackoffSeconds: 60}
+	s := newStatefulSwitcher(t, cfg, newStatefulMock("vllm-b"))
+	events, unsubscribe := s.Events().Subscribe()
+	defer unsubscribe()
+
+	targets := []string{"model-b", "model-a", "model-b"}
+	for _, target := range targets {
+		if err := s.SwitchModel(context.Background(), target); err != nil {
+			t.Fatalf("expected switch to %s to succeed, got %v", target, err)
+		}
+	}
+
+	var alert *models.StatusEvent
+	for alert == nil {
+		select {
+		case ev := <-events:
+			if ev.Cause == models.CauseThrashing {
+				alert = &ev
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected a thrashing event")
+		}
+	}
+	if alert.ModelID != "model-b" || alert.Message == "" {
+		t.Errorf("expected a thrashing warning for model-b, got %+v", alert)
+	}
+
+	err := s.SwitchModel(context.Background(), "model-a")
+	var cooldownErr *CooldownError
+	if !errors.As(err, &cooldownErr) || cooldownErr.Reason != CooldownThrashing {
+		t.Fatalf("expected the switch to back off, got %v", err)
+	}
+	if resp := s.GetModels(); resp.SwitchBackoffSeconds <= 0 {
+		t.Errorf("expected the backoff to be reported, got %d", resp.SwitchBackoffSeconds)
+	}
+
+	if err := s.SwitchModel(WithForce(context.Background()), "model-a"); err != nil {
+		t.Fatalf("expected a forced switch to succeed, got %v", err)
+	}
+}
+
+func TestThrashDetector_Window(t *testing.T) {
+	d := &thrashDetector{cfg: models.ThrashConfig{WindowSeconds: 60, MaxSwitches: 2, BackoffSeconds: 30}}
+	start := time.Now()
+
+	d.record(start)
+	d.record(start.Add(30 * time.Second))
+	// The first switch has left the window by now
+	if tripped, count := d.record(start.Add(61 * time.Second)); tripped || count != 2 {
+		t.Fatalf("expected 2 switches in the window without tripping, got %d (tripped %v)", count, tripped)
+	}
+	if tripped, _ := d.record(start.Add(62 * time.Second)); !tripped {
+		t.Fatal("expected the third switch in the window to trip the detector")
+	}
+	if remaining := d.backoffRemaining(start.Add(62 * time.Second)); remaining != 30*time.Second {
+		t.Errorf("expected a 30s backoff, got %s", remaining)
+	}
+
+	// Counting starts afresh after an alert
+	if tripped, count := d.record(start.Add(63 * time.Second)); tripped || count != 1 {
+		t.Errorf("expected counting to restart, got %d (tripped %v)", count, tripped)
+	}
+}
+
+func TestEnsureActive_QueueHeldDuringCooldown(t *testing.T) {
+	cfg := stateTestConfig()
+	cfg.Models[1].MinResidency = 100 * time.Millisecond
+	cfg.Queue.MaxWaitSeconds = 5
+	s := newStatefulSwitcher(t, cfg, newStatefulMock("vllm-b"))
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// The queued request waits out the residency instead of failing
+	start := time.Now()
+	if _, err := s.EnsureActive(context.Background(), "model-a"); err != nil {
+		t.Fatalf("expected the request to be served after the cooldown, got %v", err)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("expected the request to wait for the cooldown, waited %s", waited)
+	}
+	if s.GetModels().ActiveModel != "model-a" {
+		t.Errorf("expected model-a to be active, got %s", s.GetModels().ActiveModel)
+	}
+}
@@ -0,0 +1,40 @@
+package switcher
+
+import (
+	"context"
+
+	"github.com/zheng/homeGPT/internal/events"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// causeContextKey carries why a switcher operation is changing model statuses
+type causeContextKey struct{}
+
+// withCause tags status changes made under ctx with the given cause
+func withCause(ctx context.Context, cause models.EventCause) context.Context {
+	return context.WithValue(ctx, causeContextKey{}, cause)
+}
+
+// mark sets a model's status, attributing the change to the cause carried by ctx
+// (a switch unless tagged otherwise)
+func (s *Switcher) mark(ctx context.Context, model *models.Model, status models.ModelStatus) {
+	cause, ok := ctx.Value(causeContextKey{}).(models.EventCause)
+	if !ok {
+		cause = models.CauseSwitch
+	}
+	model.Transition(status, cause)
+}
+
+// publishEvent fans a model status change out to subscribers, metrics, the
+// state store and the audit log
+func (s *Switcher) publishEvent(event models.StatusEvent) {
+	s.persistEvent(event)
+	s.auditEvent(event)
+	s.metrics.ObserveTransition(event)
+	s.events.Publish(event)
+}
+
+// Events returns the bus that publishes model status changes
+func (s *Switcher) Events() *events.Bus {
+	return s.events
+}
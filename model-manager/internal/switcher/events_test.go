@@ -0,0 +1,111 @@
+package switcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// collectEvents drains the events received so far
+func collectEvents(ch <-chan models.StatusEvent) []models.StatusEvent {
+	var got []models.StatusEvent
+	for {
+		select {
+		case event := <-ch:
+			got = append(got, event)
+		case <-time.After(50 * time.Millisecond):
+			return got
+		}
+	}
+}
+
+func TestEvents_Switch(t *testing.T) {
+	s, _ := setupIdleSwitcher(t, 0)
+
+	ch, cancel := s.Events().Subscribe()
+	defer cancel()
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []struct {
+		id       string
+		old, new models.ModelStatus
+	}{
+		{"model-a", models.StatusActive, models.StatusSwitching},
+		{"model-a", models.StatusSwitching, models.StatusSleeping},
+		{"model-b", models.StatusSleeping, models.StatusSwitching},
+		{"model-b", models.StatusSwitching, models.StatusActive},
+	}
+
+	got := collectEvents(ch)
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d events, got %+v", len(expected), got)
+	}
+	for i, e := range expected {
+		if got[i].ModelID != e.id || got[i].OldStatus != e.old || got[i].NewStatus != e.new || got[i].Cause != models.CauseSwitch {
+			t.Errorf("event %d: expected %s %s->%s (switch), got %+v", i, e.id, e.old, e.new, got[i])
+		}
+		if got[i].Timestamp.IsZero() {
+			t.Errorf("event %d: expected a timestamp", i)
+		}
+	}
+}
+
+func TestEvents_ResyncAndIdleCauses(t *testing.T) {
+	s, mockClient := setupIdleSwitcher(t, 20*time.Millisecond)
+
+	ch, cancel := s.Events().Subscribe()
+	defer cancel()
+
+	// model-b comes up out of band, alongside model-a within the GPU memory budget
+	s.config.GPUMemoryBudgetGB = 80
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		for _, call := range mockClient.SleepCalls {
+			if call.Host == host {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	s.resyncModels(context.Background())
+
+	got := collectEvents(ch)
+	if len(got) != 1 || got[0].ModelID != "model-b" || got[0].NewStatus != models.StatusActive || got[0].Cause != models.CauseResync {
+		t.Fatalf("expected model-b active from resync, got %+v", got)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	s.reapIdleModels(context.Background())
+
+	got = collectEvents(ch)
+	if len(got) == 0 {
+		t.Fatal("expected events from idle sleep")
+	}
+	for _, event := range got {
+		if event.Cause != models.CauseIdle {
+			t.Errorf("expected idle cause, got %+v", event)
+		}
+	}
+}
+
+func TestEvents_ErrorCause(t *testing.T) {
+	s, mockClient := setupIdleSwitcher(t, 0)
+
+	ch, cancel := s.Events().Subscribe()
+	defer cancel()
+
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		return false, context.DeadlineExceeded
+	}
+	s.resyncModels(context.Background())
+
+	for _, event := range collectEvents(ch) {
+		if event.NewStatus != models.StatusError || event.Cause != models.CauseError {
+			t.Errorf("expected error transition, got %+v", event)
+		}
+	}
+}
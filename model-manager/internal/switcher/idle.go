@@ -0,0 +1,110 @@
+package switcher
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+const defaultIdleCheckInterval = 30 * time.Second
+
+// TrackRequest records an inference request in flight on a model, with the
+// priority carried by ctx. The returned func must be called when the request
+// finishes; until then the model is never put to sleep for being idle, nor by
+// a switch of a lower priority.
+func (s *Switcher) TrackRequest(ctx context.Context, modelID string) func() {
+	priority := priorityFrom(ctx)
+
+	// Held across BeginRequest so a config reload can't swap the model meanwhile
+	s.mapMu.RLock()
+	model, exists := s.models[modelID]
+	if exists {
+		model.BeginRequest(priority)
+	}
+	s.mapMu.RUnlock()
+
+	if !exists {
+		return func() {}
+	}
+
+	// Look the model up again when the request ends, since a reload may have
+	// replaced it (carrying the in-flight count over)
+	return func() {
+		s.mapMu.RLock()
+		if model, ok := s.models[modelID]; ok {
+			model.EndRequest(priority)
+		}
+		s.mapMu.RUnlock()
+		s.notifyWorkDone()
+	}
+}
+
+// runIdleReaper periodically puts idle models to sleep
+func (s *Switcher) runIdleReaper() {
+	ticker := time.NewTicker(s.idleCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.reapIdleModels(context.Background())
+	}
+}
+
+// reapIdleModels puts every active model to sleep whose idle_timeout has elapsed
+// since it last served inference traffic
+func (s *Switcher) reapIdleModels(ctx context.Context) {
+	s.mapMu.RLock()
+	modelsCopy := make(map[string]*models.Model, len(s.models))
+	for k, v := range s.models {
+		modelsCopy[k] = v
+	}
+	s.mapMu.RUnlock()
+
+	for id, m := range modelsCopy {
+		if remaining, ok := m.IdleRemaining(time.Now()); ok && remaining == 0 {
+			s.sleepIdleModel(ctx, id)
+		}
+	}
+}
+
+// sleepIdleModel puts an idle model to sleep. Requests for it arriving meanwhile
+// are queued and wake it again afterwards; other models keep serving.
+func (s *Switcher) sleepIdleModel(ctx context.Context, modelID string) {
+	defer s.drainQueue()
+
+	s.switchLock.Lock()
+	defer s.switchLock.Unlock()
+
+	s.mapMu.RLock()
+	model, exists := s.models[modelID]
+	s.mapMu.RUnlock()
+
+	// The model may have been removed by a config reload while waiting for the lock
+	if !exists {
+		return
+	}
+
+	// Held before the idle check, so a request either refreshes the idle timer
+	// first or waits for the model to wake again
+	defer s.holdModels([]string{modelID})()
+
+	// Traffic may have arrived while waiting for the lock
+	remaining, ok := model.IdleRemaining(time.Now())
+	if !ok || remaining > 0 {
+		return
+	}
+
+	log.Printf("Model %s idle for %s, putting it to sleep", modelID, model.IdleTimeout)
+
+	if err := s.releaseModel(withCause(ctx, models.CauseIdle), modelID); err != nil {
+		log.Printf("Failed to sleep idle model %s: %v", modelID, err)
+		return
+	}
+
+	next := s.mostRecentlyActive(modelID)
+	s.mapMu.Lock()
+	if s.activeModel == modelID {
+		s.activeModel = next
+	}
+	s.mapMu.Unlock()
+}
@@ -0,0 +1,207 @@
+package switcher
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/internal/vllm"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+func setupIdleSwitcher(t *testing.T, idleTimeout time.Duration) (*Switcher, *vllm.MockClient) {
+	t.Helper()
+
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive, IdleTimeout: idleTimeout},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupSleep},
+		},
+	}
+
+	mockClient := vllm.NewMockClient()
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		if host == "vllm-b" {
+			return true, nil
+		}
+		for _, call := range mockClient.SleepCalls {
+			if call.Host == host && call.Port == port {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	s := NewWithClient(cfg, mockClient, WithMaxRetries(2), WithHealthCheckInterval(10*time.Millisecond))
+	s.WaitForInit()
+
+	return s, mockClient
+}
+
+func TestReapIdleModels_SleepsIdleModel(t *testing.T) {
+	s, mockClient := setupIdleSwitcher(t, 20*time.Millisecond)
+
+	// Fresh activity: not idle yet
+	s.reapIdleModels(context.Background())
+	if len(mockClient.SleepCalls) != 0 {
+		t.Fatalf("expected no sleep before idle timeout, got %d calls", len(mockClient.SleepCalls))
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	s.reapIdleModels(context.Background())
+
+	if len(mockClient.SleepCalls) != 1 {
+		t.Fatalf("expected 1 sleep call after idle timeout, got %d", len(mockClient.SleepCalls))
+	}
+
+	if s.models["model-a"].GetStatus() != models.StatusSleeping {
+		t.Errorf("expected model-a to be sleeping, got %s", s.models["model-a"].GetStatus())
+	}
+
+	if s.GetModels().ActiveModel != "" {
+		t.Errorf("expected no active model, got '%s'", s.GetModels().ActiveModel)
+	}
+}
+
+func TestReapIdleModels_InFlightRequestKeepsAwake(t *testing.T) {
+	s, mockClient := setupIdleSwitcher(t, 20*time.Millisecond)
+
+	done := s.TrackRequest(context.Background(), "model-a")
+	time.Sleep(30 * time.Millisecond)
+	s.reapIdleModels(context.Background())
+
+	if len(mockClient.SleepCalls) != 0 {
+		t.Fatalf("expected no sleep while a request is in flight, got %d calls", len(mockClient.SleepCalls))
+	}
+
+	// The idle countdown starts when the request finishes
+	done()
+	s.reapIdleModels(context.Background())
+	if len(mockClient.SleepCalls) != 0 {
+		t.Fatalf("expected no sleep right after the request finished, got %d calls", len(mockClient.SleepCalls))
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	s.reapIdleModels(context.Background())
+	if len(mockClient.SleepCalls) != 1 {
+		t.Errorf("expected 1 sleep call once idle, got %d", len(mockClient.SleepCalls))
+	}
+}
+
+func TestReapIdleModels_NoTimeoutConfigured(t *testing.T) {
+	s, mockClient := setupIdleSwitcher(t, 0)
+
+	time.Sleep(10 * time.Millisecond)
+	s.reapIdleModels(context.Background())
+
+	if len(mockClient.SleepCalls) != 0 {
+		t.Errorf("expected no sleep without idle_timeout, got %d calls", len(mockClient.SleepCalls))
+	}
+}
+
+func TestReapIdleModels_NextRequestWakesModel(t *testing.T) {
+	s, mockClient := setupIdleSwitcher(t, 10*time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+	s.reapIdleModels(context.Background())
+
+	if _, err := s.EnsureActive(context.Background(), "model-a"); err != nil {
+		t.Fatalf("expected idle model to wake on request, got %v", err)
+	}
+
+	if len(mockClient.WakeUpCalls) != 1 {
+		t.Errorf("expected 1 wake_up call, got %d", len(mockClient.WakeUpCalls))
+	}
+
+	if s.GetModels().ActiveModel != "model-a" {
+		t.Errorf("expected model-a to be active again, got '%s'", s.GetModels().ActiveModel)
+	}
+}
+
+func TestSleepIdleModel_OtherModelsKeepServing(t *testing.T) {
+	cfg := &models.Config{
+		GPUMemoryBudgetGB: 48,
+		Queue:             models.QueueConfig{MaxWaitSeconds: 1},
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive, IdleTimeout: 10 * time.Millisecond},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupActive},
+		},
+	}
+	mockClient := newStatefulMock()
+	s := NewWithClient(cfg, mockClient, WithMaxRetries(2), WithHealthCheckInterval(10*time.Millisecond))
+	s.WaitForInit()
+
+	// Putting model-a to sleep takes until unblock is closed
+	unblock := make(chan struct{})
+	sleep := mockClient.SleepFunc
+	mockClient.SleepFunc = func(ctx context.Context, host string, port int, level int) error {
+		if host == "vllm-a" {
+			<-unblock
+		}
+		return sleep(ctx, host, port, level)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	reaped := make(chan struct{})
+	go func() {
+		s.sleepIdleModel(context.Background(), "model-a")
+		close(reaped)
+	}()
+	waitFor(t, func() bool {
+		return s.models["model-a"].GetStatus() == models.StatusSwitching
+	})
+
+	if _, err := s.EnsureActive(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected model-b to be served while model-a goes to sleep, got %v", err)
+	}
+
+	// A request for model-a waits and wakes it again
+	result := make(chan error, 1)
+	go func() {
+		_, err := s.EnsureActive(context.Background(), "model-a")
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		t.Fatalf("expected the model-a request to wait, returned early with %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(unblock)
+	<-reaped
+	if err := <-result; err != nil {
+		t.Fatalf("expected the model-a request to wake it, got %v", err)
+	}
+}
+
+func TestModelJSON_IdleRemaining(t *testing.T) {
+	s, _ := setupIdleSwitcher(t, time.Hour)
+
+	data, err := json.Marshal(s.models["model-a"])
+	if err != nil {
+		t.Fatalf("failed to marshal model: %v", err)
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("failed to unmarshal model: %v", err)
+	}
+
+	if out["idle_timeout"] != "1h0m0s" {
+		t.Errorf("expected idle_timeout '1h0m0s', got %v", out["idle_timeout"])
+	}
+
+	remaining, ok := out["idle_remaining_seconds"].(float64)
+	if !ok || remaining <= 3500 || remaining > 3600 {
+		t.Errorf("expected idle_remaining_seconds close to 3600, got %v", out["idle_remaining_seconds"])
+	}
+
+	// Sleeping models have no idle countdown
+	data, _ = json.Marshal(s.models["model-b"])
+	out = nil
+	json.Unmarshal(data, &out)
+	if _, ok := out["idle_remaining_seconds"]; ok {
+		t.Error("expected no idle_remaining_seconds for a sleeping model")
+	}
+}
@@ -0,0 +1,233 @@
+package switcher
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// defaultJobHistory is how many switch jobs are kept for polling
+const defaultJobHistory = 100
+
+// WithJobHistory sets how many asynchronous switch jobs are kept for polling
+func WithJobHistory(size int) Option {
+	return func(s *Switcher) {
+		s.jobs = newJobStore(size)
+	}
+}
+
+// switchJob tracks one asynchronous switch
+type switchJob struct {
+	mu     sync.Mutex
+	job    models.SwitchJob
+	cancel context.CancelCauseFunc // Aborts the switch, running or queued
+}
+
+// setPhase records the phase the switch has reached
+func (j *switchJob) setPhase(phase models.SwitchJobPhase) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.job.Phase = phase
+	j.job.Attempt = 0
+	j.job.MaxAttempts = 0
+}
+
+// setAttempt records the current health check attempt
+func (j *switchJob) setAttempt(attempt, maxAttempts int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.job.Phase = models.PhaseHealthChecking
+	j.job.Attempt = attempt
+	j.job.MaxAttempts = maxAttempts
+}
+
+// finish records the outcome of the switch
+func (j *switchJob) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	j.job.FinishedAt = &now
+	if errors.Is(err, ErrSwitchCanceled) {
+		j.job.Phase = models.PhaseCanceled
+		j.job.Error = err.Error()
+	} else if err != nil {
+		j.job.Phase = models.PhaseFailed
+		j.job.Error = err.Error()
+	} else {
+		j.job.Phase = models.PhaseDone
+	}
+}
+
+// snapshot returns a copy of the job with the elapsed time filled in
+func (j *switchJob) snapshot() models.SwitchJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job := j.job
+	end := time.Now()
+	if job.FinishedAt != nil {
+		end = *job.FinishedAt
+	}
+	job.ElapsedSeconds = end.Sub(job.StartedAt).Seconds()
+	return job
+}
+
+// jobStore is a bounded history of switch jobs; the oldest are dropped first
+type jobStore struct {
+	mu    sync.Mutex
+	jobs  map[string]*switchJob
+	order []string // Job IDs, oldest first
+	size  int
+}
+
+func newJobStore(size int) *jobStore {
+	return &jobStore{
+		jobs: make(map[string]*switchJob),
+		size: size,
+	}
+}
+
+// add stores a new job, dropping the oldest when the history is full
+func (st *jobStore) add(j *switchJob) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.jobs[j.job.ID] = j
+	st.order = append(st.order, j.job.ID)
+	for len(st.order) > st.size {
+		delete(st.jobs, st.order[0])
+		st.order = st.order[1:]
+	}
+}
+
+func (st *jobStore) get(id string) (*switchJob, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	j, ok := st.jobs[id]
+	return j, ok
+}
+
+// newJobID returns a random job identifier
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// jobContextKey carries the job a switch reports progress to
+type jobContextKey struct{}
+
+// reportPhase records a switch phase on the job carried by ctx, if any
+func reportPhase(ctx context.Context, phase models.SwitchJobPhase) {
+	if j, ok := ctx.Value(jobContextKey{}).(*switchJob); ok {
+		j.setPhase(phase)
+	}
+}
+
+// reportAttempt records a health check attempt on the job carried by ctx, if any
+func reportAttempt(ctx context.Context, attempt, maxAttempts int) {
+	if j, ok := ctx.Value(jobContextKey{}).(*switchJob); ok {
+		j.setAttempt(attempt, maxAttempts)
+	}
+}
+
+// StartSwitchJob starts a switch in the background and returns the job tracking
+// it. Only the actor, priority, force flag and schedule are taken from ctx; the job
+// outlives the request. A job blocked by higher-priority work or a cooldown is
+// deferred until it can go ahead, rather than failing.
+func (s *Switcher) StartSwitchJob(ctx context.Context, targetModelID string) (models.SwitchJob, error) {
+	s.mapMu.RLock()
+	_, exists := s.models[targetModelID]
+	s.mapMu.RUnlock()
+
+	if !exists {
+		return models.SwitchJob{}, fmt.Errorf("%w: %s", ErrModelNotFound, targetModelID)
+	}
+
+	j := &switchJob{job: models.SwitchJob{
+		ID:        newJobID(),
+		ModelID:   targetModelID,
+		Phase:     models.PhaseQueued,
+		StartedAt: time.Now(),
+	}}
+	// The job outlives the HTTP request that started it
+	base := WithPriority(WithActor(context.Background(), actorFrom(ctx)), priorityFrom(ctx))
+	if forced(ctx) {
+		base = WithForce(base)
+	}
+	if name, ok := ctx.Value(scheduleContextKey{}).(string); ok {
+		base = withSchedule(base, name)
+	}
+	ctx, cancel := context.WithCancelCause(context.WithValue(base, jobContextKey{}, j))
+	j.cancel = cancel
+	s.jobs.add(j)
+
+	s.switchesInFlight.Add(1)
+	go func() {
+		defer cancel(nil)
+		err := s.runDeferrableSwitch(ctx, targetModelID)
+		if err != nil {
+			log.Printf("Switch job %s to %s failed: %v", j.job.ID, targetModelID, err)
+		}
+		j.finish(err)
+	}()
+
+	return j.snapshot(), nil
+}
+
+// runDeferrableSwitch performs a switch already counted in switchesInFlight.
+// While it is blocked by higher-priority work or held back by a cooldown, the
+// job running it is deferred, and the switch is retried once the work has
+// finished or the cooldown has ended.
+func (s *Switcher) runDeferrableSwitch(ctx context.Context, targetModelID string) error {
+	for {
+		err := s.runSwitch(ctx, targetModelID)
+
+		var preemptErr *PreemptionError
+		var cooldownErr *CooldownError
+		switch {
+		case errors.As(err, &preemptErr):
+			log.Printf("Deferring switch to %s until %s finishes its priority %d requests",
+				targetModelID, preemptErr.ModelID, preemptErr.Priority)
+			reportPhase(ctx, models.PhaseDeferred)
+			err = s.waitForWork(ctx, preemptErr)
+		case errors.As(err, &cooldownErr):
+			log.Printf("Deferring switch to %s for %s: %v", targetModelID, cooldownErr.RetryAfter.Round(time.Second), cooldownErr)
+			reportPhase(ctx, models.PhaseDeferred)
+			err = sleepCtx(ctx, cooldownErr.RetryAfter)
+		default:
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("switch to %s aborted: %w", targetModelID, err)
+		}
+		s.switchesInFlight.Add(1)
+	}
+}
+
+// sleepCtx waits for d, returning the cause if ctx is done first
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// GetSwitchJob returns a switch job by ID
+func (s *Switcher) GetSwitchJob(id string) (models.SwitchJob, bool) {
+	j, ok := s.jobs.get(id)
+	if !ok {
+		return models.SwitchJob{}, false
+	}
+	return j.snapshot(), true
+}
@@ -0,0 +1,112 @@
+package switcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+func TestJobStore_BoundedHistory(t *testing.T) {
+	st := newJobStore(2)
+
+	for i := 0; i < 3; i++ {
+		st.add(&switchJob{job: models.SwitchJob{ID: fmt.Sprintf("job-%d", i)}})
+	}
+
+	if _, ok := st.get("job-0"); ok {
+		t.Error("expected oldest job to be dropped")
+	}
+	for _, id := range []string{"job-1", "job-2"} {
+		if _, ok := st.get(id); !ok {
+			t.Errorf("expected %s to be kept", id)
+		}
+	}
+}
+
+func TestStartSwitchJob_ReportsPhases(t *testing.T) {
+	s, unblock := setupBlockedSwitch(t)
+
+	job, err := s.StartSwitchJob(context.Background(), "model-b")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if job.ID == "" {
+		t.Fatal("expected a job ID")
+	}
+
+	// model-a is put to sleep, then the wake-up of model-b blocks
+	waitFor(t, func() bool {
+		j, _ := s.GetSwitchJob(job.ID)
+		return j.Phase == models.PhaseWakingTarget
+	})
+
+	close(unblock)
+
+	waitFor(t, func() bool {
+		j, _ := s.GetSwitchJob(job.ID)
+		return j.Phase == models.PhaseDone
+	})
+
+	j, _ := s.GetSwitchJob(job.ID)
+	if j.FinishedAt == nil || j.Error != "" {
+		t.Errorf("expected finished job without error, got %+v", j)
+	}
+	if s.GetModels().ActiveModel != "model-b" {
+		t.Errorf("expected model-b active, got '%s'", s.GetModels().ActiveModel)
+	}
+}
+
+func TestStartSwitchJob_RecordsFailure(t *testing.T) {
+	s, mockClient := setupIdleSwitcher(t, 0)
+	mockClient.HealthFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		if host == "vllm-b" {
+			return false, errors.New("not ready")
+		}
+		return true, nil
+	}
+
+	job, err := s.StartSwitchJob(context.Background(), "model-b")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	waitFor(t, func() bool {
+		j, _ := s.GetSwitchJob(job.ID)
+		return j.Phase == models.PhaseFailed
+	})
+
+	j, _ := s.GetSwitchJob(job.ID)
+	if j.Error == "" {
+		t.Error("expected error message on failed job")
+	}
+	if j.ElapsedSeconds <= 0 {
+		t.Errorf("expected positive elapsed time, got %f", j.ElapsedSeconds)
+	}
+}
+
+func TestReportAttempt(t *testing.T) {
+	j := &switchJob{job: models.SwitchJob{ID: "job", StartedAt: time.Now()}}
+	ctx := context.WithValue(context.Background(), jobContextKey{}, j)
+
+	reportAttempt(ctx, 3, 450)
+
+	snap := j.snapshot()
+	if snap.Phase != models.PhaseHealthChecking || snap.Attempt != 3 || snap.MaxAttempts != 450 {
+		t.Errorf("unexpected job progress: %+v", snap)
+	}
+
+	// Contexts without a job are ignored
+	reportPhase(context.Background(), models.PhaseDone)
+}
+
+func TestStartSwitchJob_UnknownModel(t *testing.T) {
+	s, _ := setupIdleSwitcher(t, 0)
+
+	if _, err := s.StartSwitchJob(context.Background(), "nonexistent"); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("expected ErrModelNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package switcher
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// awakeModels returns the active models other than excludeID, least recently used first
+func (s *Switcher) awakeModels(excludeID string) []*models.Model {
+	s.mapMu.RLock()
+	awake := make([]*models.Model, 0, len(s.models))
+	for id, m := range s.models {
+		if id != excludeID && m.GetStatus() == models.StatusActive {
+			awake = append(awake, m)
+		}
+	}
+	s.mapMu.RUnlock()
+
+	sort.Slice(awake, func(i, j int) bool {
+		return lessRecentlyUsed(awake[i], awake[j])
+	})
+	return awake
+}
+
+// lessRecentlyUsed orders models by last active time, never-active first, then by ID
+func lessRecentlyUsed(a, b *models.Model) bool {
+	ta, tb := a.GetLastActive(), b.GetLastActive()
+	switch {
+	case ta == nil && tb == nil:
+		return a.ID < b.ID
+	case ta == nil:
+		return true
+	case tb == nil:
+		return false
+	case ta.Equal(*tb):
+		return a.ID < b.ID
+	default:
+		return ta.Before(*tb)
+	}
+}
+
+// planEvictions returns the awake models to put to sleep, least recently used
+// first, so that the target fits on its GPUs and in the GPU memory budget.
+// With neither configured every other awake model is evicted, keeping one
+// active model at a time.
+func (s *Switcher) planEvictions(target *models.Model) ([]string, error) {
+	awake := s.awakeModels(target.ID)
+
+	budget := s.config.GPUMemoryBudgetGB
+	if budget <= 0 && len(s.config.GPUs) == 0 {
+		evict := make([]string, 0, len(awake))
+		for _, m := range awake {
+			evict = append(evict, m.ID)
+		}
+		return evict, nil
+	}
+
+	evicted := make(map[string]bool)
+	var evict []string
+
+	// Only models sharing a device with the target can conflict with it
+	numDevices := len(s.config.GPUs)
+	targetShare := target.DeviceShareGB(numDevices)
+	for _, dev := range s.config.GPUs {
+		if !s.usesDevice(target, dev.ID) {
+			continue
+		}
+		if targetShare > dev.MemoryGB {
+			return nil, fmt.Errorf("model %s needs %.1f GB on gpu %d, which has %.1f GB",
+				target.ID, targetShare, dev.ID, dev.MemoryGB)
+		}
+
+		var used float64
+		for _, m := range awake {
+			if !evicted[m.ID] && s.usesDevice(m, dev.ID) {
+				used += m.DeviceShareGB(numDevices)
+			}
+		}
+
+		over := used + targetShare - dev.MemoryGB
+		for _, m := range awake {
+			if over <= 0 {
+				break
+			}
+			if evicted[m.ID] || !s.usesDevice(m, dev.ID) {
+				continue
+			}
+			evicted[m.ID] = true
+			evict = append(evict, m.ID)
+			over -= m.DeviceShareGB(numDevices)
+		}
+	}
+
+	if budget > 0 {
+		if target.GPUMemoryGB > budget {
+			return nil, fmt.Errorf("model %s needs %.1f GB but the GPU memory budget is %.1f GB",
+				target.ID, target.GPUMemoryGB, budget)
+		}
+
+		var used float64
+		for _, m := range awake {
+			if !evicted[m.ID] {
+				used += m.GPUMemoryGB
+			}
+		}
+
+		over := used + target.GPUMemoryGB - budget
+		for _, m := range awake {
+			if over <= 0 {
+				break
+			}
+			if evicted[m.ID] {
+				continue
+			}
+			evicted[m.ID] = true
+			evict = append(evict, m.ID)
+			over -= m.GPUMemoryGB
+		}
+	}
+
+	return evict, nil
+}
+
+// usesDevice reports whether a model is placed on the given GPU. Models without
+// explicit gpus are treated as spanning every configured device.
+func (s *Switcher) usesDevice(m *models.Model, deviceID int) bool {
+	if len(m.GPUs) == 0 {
+		return true
+	}
+	for _, id := range m.GPUs {
+		if id == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// deviceUsage reports how much of each configured GPU the awake models occupy
+func (s *Switcher) deviceUsage(awake []*models.Model) []models.DeviceUsage {
+	if len(s.config.GPUs) == 0 {
+		return nil
+	}
+
+	usage := make([]models.DeviceUsage, 0, len(s.config.GPUs))
+	for _, dev := range s.config.GPUs {
+		u := models.DeviceUsage{ID: dev.ID, MemoryGB: dev.MemoryGB, Models: []string{}}
+		for _, m := range awake {
+			if s.usesDevice(m, dev.ID) {
+				u.UsedGB += m.DeviceShareGB(len(s.config.GPUs))
+				u.Models = append(u.Models, m.ID)
+			}
+		}
+		sort.Strings(u.Models)
+		usage = append(usage, u)
+	}
+	return usage
+}
+
+// mostRecentlyActive returns the awake model other than excludeID that was used
+// most recently, or "" if none is awake
+func (s *Switcher) mostRecentlyActive(excludeID string) string {
+	awake := s.awakeModels(excludeID)
+	if len(awake) == 0 {
+		return ""
+	}
+	return awake[len(awake)-1].ID
+}
@@ -0,0 +1,280 @@
+package switcher
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/internal/vllm"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// setupBudgetSwitcher creates a switcher with a 24 GB budget where model-a (8 GB)
+// and model-b (12 GB) start awake and model-c (12 GB) sleeps
+func setupBudgetSwitcher(t *testing.T) (*Switcher, *vllm.MockClient) {
+	t.Helper()
+
+	return newPlacementSwitcher(t, &models.Config{
+		GPUMemoryBudgetGB: 24,
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, GPUMemoryGB: 8, StartupMode: models.StartupActive},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, GPUMemoryGB: 12, StartupMode: models.StartupActive},
+			{ID: "model-c", ContainerName: "vllm-c", Port: 8000, GPUMemoryGB: 12, StartupMode: models.StartupSleep},
+		},
+	})
+}
+
+// newPlacementSwitcher creates a switcher whose mock vLLM instances report
+// sleeping according to their startup mode and later sleep/wake calls
+func newPlacementSwitcher(t *testing.T, cfg *models.Config) (*Switcher, *vllm.MockClient) {
+	t.Helper()
+
+	var asleep []string
+	for i := range cfg.Models {
+		if cfg.Models[i].StartupMode == models.StartupSleep {
+			asleep = append(asleep, cfg.Models[i].ContainerName)
+		}
+	}
+	mockClient := newStatefulMock(asleep...)
+
+	s := NewWithClient(cfg, mockClient, WithMaxRetries(2), WithHealthCheckInterval(10*time.Millisecond))
+	s.WaitForInit()
+
+	return s, mockClient
+}
+
+func TestSwitchModel_FitsWithinBudget(t *testing.T) {
+	s, mockClient := setupBudgetSwitcher(t)
+
+	// model-a is the least recently used; evicting it leaves room for model-c
+	s.models["model-b"].Touch()
+
+	if err := s.SwitchModel(context.Background(), "model-c"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(mockClient.SleepCalls) != 1 || mockClient.SleepCalls[0].Host != "vllm-a" {
+		t.Fatalf("expected only model-a to be evicted, got %+v", mockClient.SleepCalls)
+	}
+
+	resp := s.GetModels()
+	if !reflect.DeepEqual(resp.ActiveModels, []string{"model-b", "model-c"}) {
+		t.Errorf("expected model-b and model-c active, got %v", resp.ActiveModels)
+	}
+	if resp.ActiveModel != "model-c" {
+		t.Errorf("expected model-c as active model, got '%s'", resp.ActiveModel)
+	}
+	if resp.GPUMemoryUsedGB != 24 {
+		t.Errorf("expected 24 GB in use, got %.1f", resp.GPUMemoryUsedGB)
+	}
+}
+
+func TestSwitchModel_NoEvictionWhenRoomLeft(t *testing.T) {
+	s, mockClient := setupBudgetSwitcher(t)
+
+	// Sleep model-b to free up room, then bring model-c in beside model-a
+	if err := s.sleepModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("failed to sleep model-b: %v", err)
+	}
+	mockClient.SleepCalls = nil
+
+	if err := s.SwitchModel(context.Background(), "model-c"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(mockClient.SleepCalls) != 0 {
+		t.Errorf("expected no evictions, got %+v", mockClient.SleepCalls)
+	}
+
+	if s.models["model-a"].GetStatus() != models.StatusActive {
+		t.Errorf("expected model-a to stay active, got %s", s.models["model-a"].GetStatus())
+	}
+}
+
+func TestEnsureActive_ServesModelsTheSwitchLeavesAwake(t *testing.T) {
+	s, mockClient := newPlacementSwitcher(t, &models.Config{
+		GPUMemoryBudgetGB: 48,
+		Queue:             models.QueueConfig{MaxWaitSeconds: 1},
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, GPUMemoryGB: 8, StartupMode: models.StartupActive},
+			{ID: "model-c", ContainerName: "vllm-c", Port: 8000, GPUMemoryGB: 12, StartupMode: models.StartupSleep},
+		},
+	})
+
+	// model-c wakes beside model-a, but takes until unblock is closed
+	unblock := make(chan struct{})
+	wakeUp := mockClient.WakeUpFunc
+	mockClient.WakeUpFunc = func(ctx context.Context, host string, port int) error {
+		if host == "vllm-c" {
+			<-unblock
+		}
+		return wakeUp(ctx, host, port)
+	}
+
+	switchDone := make(chan error, 1)
+	go func() {
+		switchDone <- s.SwitchModel(context.Background(), "model-c")
+	}()
+	waitFor(t, func() bool {
+		return s.models["model-c"].GetStatus() == models.StatusSwitching
+	})
+
+	// The switch doesn't touch model-a, so its requests go straight through
+	if _, err := s.EnsureActive(context.Background(), "model-a"); err != nil {
+		t.Fatalf("expected model-a to be served during the switch, got %v", err)
+	}
+
+	// Requests for the target still wait for it
+	result := make(chan error, 1)
+	go func() {
+		_, err := s.EnsureActive(context.Background(), "model-c")
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		t.Fatalf("expected the model-c request to wait for the switch, returned early with %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(unblock)
+	if err := <-switchDone; err != nil {
+		t.Fatalf("expected switch to succeed, got %v", err)
+	}
+	if err := <-result; err != nil {
+		t.Fatalf("expected queued model-c request to be served, got %v", err)
+	}
+}
+
+func TestSwitchModel_AlreadyAwakeWithinBudget(t *testing.T) {
+	s, mockClient := setupBudgetSwitcher(t)
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(mockClient.SleepCalls) != 0 || len(mockClient.WakeUpCalls) != 0 {
+		t.Errorf("expected no sleep/wake calls, got %d/%d", len(mockClient.SleepCalls), len(mockClient.WakeUpCalls))
+	}
+
+	if s.GetModels().ActiveModel != "model-b" {
+		t.Errorf("expected model-b as active model, got '%s'", s.GetModels().ActiveModel)
+	}
+}
+
+func TestPlanEvictions_LeastRecentlyUsedFirst(t *testing.T) {
+	s, _ := setupBudgetSwitcher(t)
+
+	s.models["model-a"].Touch()
+	time.Sleep(time.Millisecond)
+	s.models["model-b"].Touch()
+
+	// model-c needs 12 GB with 20 GB in use: only model-a (8 GB) must go
+	evict, err := s.planEvictions(s.models["model-c"])
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(evict, []string{"model-a"}) {
+		t.Errorf("expected [model-a], got %v", evict)
+	}
+
+	// A 20 GB model needs both evicted, model-a first
+	big := &models.Model{ID: "model-d", GPUMemoryGB: 20}
+	evict, err = s.planEvictions(big)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(evict, []string{"model-a", "model-b"}) {
+		t.Errorf("expected [model-a model-b], got %v", evict)
+	}
+
+	// A model larger than the whole budget never fits
+	if _, err := s.planEvictions(&models.Model{ID: "model-e", GPUMemoryGB: 32}); err == nil {
+		t.Error("expected error for model exceeding budget, got nil")
+	}
+}
+
+// setupDeviceSwitcher creates a switcher with two 48 GB GPUs: model-a uses gpu 0,
+// model-b uses gpu 1 and model-c spans both
+func setupDeviceSwitcher(t *testing.T) (*Switcher, *vllm.MockClient) {
+	t.Helper()
+
+	return newPlacementSwitcher(t, &models.Config{
+		GPUs: []models.GPUDevice{{ID: 0, MemoryGB: 48}, {ID: 1, MemoryGB: 48}},
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, GPUMemoryGB: 40, GPUs: []int{0}, StartupMode: models.StartupActive},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, GPUMemoryGB: 40, GPUs: []int{1}, StartupMode: models.StartupSleep},
+			{ID: "model-c", ContainerName: "vllm-c", Port: 8000, GPUMemoryGB: 60, GPUs: []int{0, 1}, StartupMode: models.StartupSleep},
+			{ID: "model-d", ContainerName: "vllm-d", Port: 8000, GPUMemoryGB: 20, GPUs: []int{1}, StartupMode: models.StartupSleep},
+		},
+	})
+}
+
+func TestSwitchModel_SeparateDevicesStayAwake(t *testing.T) {
+	s, mockClient := setupDeviceSwitcher(t)
+
+	// model-b lives on gpu 1 only, so model-a on gpu 0 is left alone
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(mockClient.SleepCalls) != 0 {
+		t.Fatalf("expected no evictions, got %+v", mockClient.SleepCalls)
+	}
+
+	resp := s.GetModels()
+	if !reflect.DeepEqual(resp.ActiveModels, []string{"model-a", "model-b"}) {
+		t.Errorf("expected model-a and model-b active, got %v", resp.ActiveModels)
+	}
+
+	if len(resp.Devices) != 2 {
+		t.Fatalf("expected 2 devices, got %d", len(resp.Devices))
+	}
+	for _, dev := range resp.Devices {
+		if dev.UsedGB != 40 || len(dev.Models) != 1 {
+			t.Errorf("expected gpu %d to hold one 40 GB model, got %+v", dev.ID, dev)
+		}
+	}
+}
+
+func TestSwitchModel_EvictsOnlyConflictingDevice(t *testing.T) {
+	s, mockClient := setupDeviceSwitcher(t)
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("failed to activate model-b: %v", err)
+	}
+
+	// model-d (20 GB on gpu 1) doesn't fit next to model-b (40 GB on gpu 1)
+	if err := s.SwitchModel(context.Background(), "model-d"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(mockClient.SleepCalls) != 1 || mockClient.SleepCalls[0].Host != "vllm-b" {
+		t.Fatalf("expected only model-b to be evicted, got %+v", mockClient.SleepCalls)
+	}
+
+	if s.models["model-a"].GetStatus() != models.StatusActive {
+		t.Errorf("expected model-a to stay active, got %s", s.models["model-a"].GetStatus())
+	}
+}
+
+func TestSwitchModel_SpanningModelEvictsBothDevices(t *testing.T) {
+	s, mockClient := setupDeviceSwitcher(t)
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("failed to activate model-b: %v", err)
+	}
+
+	// model-c needs 30 GB on each GPU, so both 40 GB models must go
+	if err := s.SwitchModel(context.Background(), "model-c"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(mockClient.SleepCalls) != 2 {
+		t.Fatalf("expected 2 evictions, got %+v", mockClient.SleepCalls)
+	}
+
+	if !reflect.DeepEqual(s.GetModels().ActiveModels, []string{"model-c"}) {
+		t.Errorf("expected only model-c active, got %v", s.GetModels().ActiveModels)
+	}
+}
@@ -0,0 +1,130 @@
+package switcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrHigherPriorityWork is matched by a PreemptionError
+var ErrHigherPriorityWork = errors.New("model busy with higher-priority work")
+
+// PreemptionError is returned when a switch would put to sleep a model serving
+// requests of a higher priority than the switch's own
+type PreemptionError struct {
+	ModelID   string // Model that would be put to sleep
+	Priority  int    // Highest priority of the requests in flight on it
+	Requested int    // Priority of the switch
+}
+
+func (e *PreemptionError) Error() string {
+	return fmt.Sprintf("model %s is serving priority %d requests, above the switch's priority %d",
+		e.ModelID, e.Priority, e.Requested)
+}
+
+// Is lets errors.Is match ErrHigherPriorityWork
+func (e *PreemptionError) Is(target error) bool {
+	return target == ErrHigherPriorityWork
+}
+
+// priorityContextKey carries the priority of switches and requests made under a context
+type priorityContextKey struct{}
+
+// forceContextKey marks switches that may preempt higher-priority work
+type forceContextKey struct{}
+
+// WithPriority sets the priority of switches and inference requests made under
+// ctx. A switch may not put to sleep a model serving requests of a higher
+// priority. The default priority is 0.
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityContextKey{}, priority)
+}
+
+// WithForce lets switches made under ctx preempt higher-priority work
+func WithForce(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceContextKey{}, true)
+}
+
+// priorityFrom returns the priority carried by ctx, or 0
+func priorityFrom(ctx context.Context) int {
+	priority, _ := ctx.Value(priorityContextKey{}).(int)
+	return priority
+}
+
+// forced reports whether ctx lets switches preempt higher-priority work
+func forced(ctx context.Context) bool {
+	force, _ := ctx.Value(forceContextKey{}).(bool)
+	return force
+}
+
+// checkPreemption returns a PreemptionError if putting the given models to sleep
+// would cut off requests of a higher priority than the switch's, unless the
+// switch is forced
+func (s *Switcher) checkPreemption(ctx context.Context, targetModelID string, evict []string) error {
+	priority := priorityFrom(ctx)
+	for _, id := range evict {
+		s.mapMu.RLock()
+		model, ok := s.models[id]
+		s.mapMu.RUnlock()
+		if !ok {
+			continue
+		}
+
+		busy, ok := model.BusyPriority()
+		if !ok || busy <= priority {
+			continue
+		}
+		if forced(ctx) {
+			log.Printf("Forcing switch to %s past priority %d requests on %s", targetModelID, busy, id)
+			continue
+		}
+		return &PreemptionError{ModelID: id, Priority: busy, Requested: priority}
+	}
+	return nil
+}
+
+// waitForWork waits until the model that blocked a switch no longer serves
+// requests of a higher priority than the switch's
+func (s *Switcher) waitForWork(ctx context.Context, blocked *PreemptionError) error {
+	for {
+		// Taken before checking, so a request finishing in between is not missed
+		done := s.workDoneSignal()
+
+		s.mapMu.RLock()
+		model, ok := s.models[blocked.ModelID]
+		s.mapMu.RUnlock()
+		if !ok {
+			return nil
+		}
+		if busy, ok := model.BusyPriority(); !ok || busy <= blocked.Requested {
+			return nil
+		}
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	}
+}
+
+// workDoneSignal returns a channel closed the next time an inference request finishes
+func (s *Switcher) workDoneSignal() <-chan struct{} {
+	s.workMu.Lock()
+	defer s.workMu.Unlock()
+	if s.workDone == nil {
+		s.workDone = make(chan struct{})
+	}
+	return s.workDone
+}
+
+// notifyWorkDone wakes switches deferred behind requests in flight
+func (s *Switcher) notifyWorkDone() {
+	s.workMu.Lock()
+	defer s.workMu.Unlock()
+	if s.workDone != nil {
+		close(s.workDone)
+		s.workDone = nil
+	}
+}
@@ -0,0 +1,118 @@
+package switcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+func TestSwitchModel_RejectedByHigherPriorityWork(t *testing.T) {
+	s := newStatefulSwitcher(t, stateTestConfig(), newStatefulMock("vllm-b"))
+
+	done := s.TrackRequest(WithPriority(context.Background(), 10), "model-a")
+	defer done()
+
+	err := s.SwitchModel(WithPriority(context.Background(), 5), "model-b")
+	var preemptErr *PreemptionError
+	if !errors.As(err, &preemptErr) || !errors.Is(err, ErrHigherPriorityWork) {
+		t.Fatalf("expected a PreemptionError, got %v", err)
+	}
+	if preemptErr.ModelID != "model-a" || preemptErr.Priority != 10 || preemptErr.Requested != 5 {
+		t.Errorf("expected model-a busy at priority 10 over 5, got %+v", preemptErr)
+	}
+	if status := s.models["model-a"].GetStatus(); status != models.StatusActive {
+		t.Errorf("expected model-a to keep serving, got %s", status)
+	}
+
+	// Equal priority preempts, as before priorities existed
+	if err := s.SwitchModel(WithPriority(context.Background(), 10), "model-b"); err != nil {
+		t.Fatalf("expected a switch of equal priority to succeed, got %v", err)
+	}
+}
+
+func TestSwitchModel_ForcePreemptsHigherPriorityWork(t *testing.T) {
+	s := newStatefulSwitcher(t, stateTestConfig(), newStatefulMock("vllm-b"))
+
+	done := s.TrackRequest(WithPriority(context.Background(), 10), "model-a")
+	defer done()
+
+	if err := s.SwitchModel(WithForce(context.Background()), "model-b"); err != nil {
+		t.Fatalf("expected a forced switch to succeed, got %v", err)
+	}
+	if s.GetModels().ActiveModel != "model-b" {
+		t.Errorf("expected model-b to be active, got %s", s.GetModels().ActiveModel)
+	}
+}
+
+func TestStartSwitchJob_DeferredUntilWorkFinishes(t *testing.T) {
+	s := newStatefulSwitcher(t, stateTestConfig(), newStatefulMock("vllm-b"))
+
+	done := s.TrackRequest(WithPriority(context.Background(), 10), "model-a")
+
+	job, err := s.StartSwitchJob(context.Background(), "model-b")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	waitFor(t, func() bool {
+		job, _ := s.GetSwitchJob(job.ID)
+		return job.Phase == models.PhaseDeferred
+	})
+
+	// A lower-priority request finishing doesn't unblock the job
+	other := s.TrackRequest(context.Background(), "model-a")
+	other()
+	time.Sleep(20 * time.Millisecond)
+	if got, _ := s.GetSwitchJob(job.ID); got.Phase != models.PhaseDeferred {
+		t.Fatalf("expected the job to stay deferred, got %s", got.Phase)
+	}
+
+	done()
+	if got := finishedJob(t, s, job.ID); got.Phase != models.PhaseDone {
+		t.Fatalf("expected the job to finish once the work did, got %+v", got)
+	}
+	if s.GetModels().ActiveModel != "model-b" {
+		t.Errorf("expected model-b to be active, got %s", s.GetModels().ActiveModel)
+	}
+}
+
+func TestStartSwitchJob_CancelWhileDeferred(t *testing.T) {
+	s := newStatefulSwitcher(t, stateTestConfig(), newStatefulMock("vllm-b"))
+
+	done := s.TrackRequest(WithPriority(context.Background(), 10), "model-a")
+	defer done()
+
+	job, _ := s.StartSwitchJob(context.Background(), "model-b")
+	waitFor(t, func() bool {
+		job, _ := s.GetSwitchJob(job.ID)
+		return job.Phase == models.PhaseDeferred
+	})
+
+	if _, err := s.CancelSwitchJob(job.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := finishedJob(t, s, job.ID); got.Phase != models.PhaseCanceled {
+		t.Errorf("expected the deferred job to be canceled, got %+v", got)
+	}
+}
+
+func TestEnsureActive_QueuedSwitchTakesWaiterPriority(t *testing.T) {
+	s := newStatefulSwitcher(t, stateTestConfig(), newStatefulMock("vllm-b"))
+
+	done := s.TrackRequest(WithPriority(context.Background(), 5), "model-a")
+	defer done()
+
+	// A request outranking the work on model-a switches model-b in
+	if _, err := s.EnsureActive(WithPriority(context.Background(), 10), "model-b"); err != nil {
+		t.Fatalf("expected a higher-priority request to switch model-b in, got %v", err)
+	}
+
+	// One that doesn't is turned away
+	done = s.TrackRequest(WithPriority(context.Background(), 10), "model-b")
+	defer done()
+	if _, err := s.EnsureActive(context.Background(), "model-a"); !errors.Is(err, ErrHigherPriorityWork) {
+		t.Errorf("expected a lower-priority request to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package switcher
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultQueueMaxDepth = 32
+	defaultQueueMaxWait  = 5 * time.Minute
+	queueRetryAfter      = 5 * time.Second
+)
+
+// QueueError is returned when an inference request cannot be held until its
+// model becomes active, either because the queue is full or the wait timed out
+type QueueError struct {
+	ModelID    string
+	Reason     string        // "full" or "timeout"
+	RetryAfter time.Duration // Suggested delay before the caller retries
+}
+
+func (e *QueueError) Error() string {
+	if e.Reason == "full" {
+		return fmt.Sprintf("request queue for model %s is full", e.ModelID)
+	}
+	return fmt.Sprintf("timed out waiting in queue for model %s", e.ModelID)
+}
+
+// waiter is a single request held in the queue
+type waiter struct {
+	enqueued time.Time
+	priority int
+	done     chan error // Receives nil once the model is active, or the reason it never will be
+}
+
+// requestQueue holds inference requests per model, in FIFO order, while a
+// switch in flight is changing the model or it is not yet active
+type requestQueue struct {
+	mu       sync.Mutex
+	waiters  map[string][]*waiter
+	maxDepth int
+}
+
+func newRequestQueue(maxDepth int) *requestQueue {
+	return &requestQueue{
+		waiters:  make(map[string][]*waiter),
+		maxDepth: maxDepth,
+	}
+}
+
+// enqueue adds a waiter of the given priority to the back of a model's queue
+func (q *requestQueue) enqueue(modelID string, priority int) (*waiter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.waiters[modelID]) >= q.maxDepth {
+		return nil, &QueueError{ModelID: modelID, Reason: "full", RetryAfter: queueRetryAfter}
+	}
+
+	w := &waiter{
+		enqueued: time.Now(),
+		priority: priority,
+		done:     make(chan error, 1),
+	}
+	q.waiters[modelID] = append(q.waiters[modelID], w)
+	return w, nil
+}
+
+// remove drops a waiter that gave up (timeout or cancelled request)
+func (q *requestQueue) remove(modelID string, w *waiter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	list := q.waiters[modelID]
+	for i, other := range list {
+		if other == w {
+			q.waiters[modelID] = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(q.waiters[modelID]) == 0 {
+		delete(q.waiters, modelID)
+	}
+}
+
+// release wakes every waiter of a model in FIFO order with the given result
+func (q *requestQueue) release(modelID string, err error) {
+	q.mu.Lock()
+	list := q.waiters[modelID]
+	delete(q.waiters, modelID)
+	q.mu.Unlock()
+
+	for _, w := range list {
+		w.done <- err
+	}
+}
+
+// modelIDs returns the models that currently have waiters
+func (q *requestQueue) modelIDs() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ids := make([]string, 0, len(q.waiters))
+	for id := range q.waiters {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// next returns the model whose oldest waiter has been queued the longest
+func (q *requestQueue) next() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var nextID string
+	var oldest time.Time
+	for id, list := range q.waiters {
+		if len(list) == 0 {
+			continue
+		}
+		if nextID == "" || list[0].enqueued.Before(oldest) {
+			nextID = id
+			oldest = list[0].enqueued
+		}
+	}
+	return nextID, nextID != ""
+}
+
+// priority returns the highest priority of a model's waiters
+func (q *requestQueue) priority(modelID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	list := q.waiters[modelID]
+	if len(list) == 0 {
+		return 0
+	}
+	highest := list[0].priority
+	for _, w := range list[1:] {
+		highest = max(highest, w.priority)
+	}
+	return highest
+}
+
+// holdModels queues requests for the models the switch or sleep holding
+// switchLock is about to change, until the returned func is called
+func (s *Switcher) holdModels(modelIDs []string) func() {
+	s.runningMu.Lock()
+	s.held = modelIDs
+	s.runningMu.Unlock()
+
+	return func() {
+		s.runningMu.Lock()
+		s.held = nil
+		s.runningMu.Unlock()
+	}
+}
+
+// isHeld reports whether requests for a model wait because a switch or sleep
+// is changing it
+func (s *Switcher) isHeld(modelID string) bool {
+	s.runningMu.Lock()
+	defer s.runningMu.Unlock()
+	return slices.Contains(s.held, modelID)
+}
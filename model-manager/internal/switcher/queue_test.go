@@ -0,0 +1,248 @@
+package switcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/internal/vllm"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+func TestRequestQueue_EnqueueMaxDepth(t *testing.T) {
+	q := newRequestQueue(2)
+
+	for i := 0; i < 2; i++ {
+		if _, err := q.enqueue("model-a", 0); err != nil {
+			t.Fatalf("expected no error for waiter %d, got %v", i, err)
+		}
+	}
+
+	_, err := q.enqueue("model-a", 0)
+	var queueErr *QueueError
+	if !errors.As(err, &queueErr) || queueErr.Reason != "full" {
+		t.Fatalf("expected full QueueError, got %v", err)
+	}
+
+	// Other models have their own queue
+	if _, err := q.enqueue("model-b", 0); err != nil {
+		t.Errorf("expected model-b queue to accept, got %v", err)
+	}
+}
+
+func TestRequestQueue_ReleaseFIFO(t *testing.T) {
+	q := newRequestQueue(10)
+
+	first, _ := q.enqueue("model-a", 0)
+	second, _ := q.enqueue("model-a", 0)
+
+	releaseErr := errors.New("switch failed")
+	q.release("model-a", releaseErr)
+
+	if err := <-first.done; err != releaseErr {
+		t.Errorf("expected first waiter to receive release error, got %v", err)
+	}
+	if err := <-second.done; err != releaseErr {
+		t.Errorf("expected second waiter to receive release error, got %v", err)
+	}
+
+	if len(q.modelIDs()) != 0 {
+		t.Errorf("expected queue to be empty after release, got %v", q.modelIDs())
+	}
+}
+
+func TestRequestQueue_Remove(t *testing.T) {
+	q := newRequestQueue(10)
+
+	first, _ := q.enqueue("model-a", 0)
+	second, _ := q.enqueue("model-a", 0)
+
+	q.remove("model-a", first)
+
+	if len(q.waiters["model-a"]) != 1 || q.waiters["model-a"][0] != second {
+		t.Error("expected only the second waiter to remain")
+	}
+
+	q.remove("model-a", second)
+
+	if _, ok := q.waiters["model-a"]; ok {
+		t.Error("expected empty model queue to be deleted")
+	}
+}
+
+func TestRequestQueue_NextOldest(t *testing.T) {
+	q := newRequestQueue(10)
+
+	if _, ok := q.next(); ok {
+		t.Error("expected no next model for empty queue")
+	}
+
+	q.enqueue("model-b", 0)
+	time.Sleep(time.Millisecond)
+	q.enqueue("model-a", 0)
+
+	next, ok := q.next()
+	if !ok || next != "model-b" {
+		t.Errorf("expected model-b (oldest waiter), got %q", next)
+	}
+}
+
+// waitFor polls a condition until it holds or the test times out
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// setupBlockedSwitch creates a switcher whose wake-up of model-b blocks until unblock is closed
+func setupBlockedSwitch(t *testing.T, opts ...Option) (*Switcher, chan struct{}) {
+	t.Helper()
+
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupSleep},
+		},
+	}
+
+	unblock := make(chan struct{})
+	mockClient := newStatefulMock("vllm-b")
+	wakeUp := mockClient.WakeUpFunc
+	mockClient.WakeUpFunc = func(ctx context.Context, host string, port int) error {
+		if host == "vllm-b" {
+			<-unblock
+		}
+		return wakeUp(ctx, host, port)
+	}
+
+	opts = append([]Option{WithMaxRetries(2), WithHealthCheckInterval(10 * time.Millisecond)}, opts...)
+	s := NewWithClient(cfg, mockClient, opts...)
+	s.WaitForInit()
+
+	return s, unblock
+}
+
+func TestEnsureActive_WaitsForInFlightSwitch(t *testing.T) {
+	s, unblock := setupBlockedSwitch(t)
+
+	switchDone := make(chan error, 1)
+	go func() {
+		switchDone <- s.SwitchModel(context.Background(), "model-b")
+	}()
+
+	waitFor(t, func() bool {
+		return s.models["model-b"].GetStatus() == models.StatusSwitching
+	})
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := s.EnsureActive(context.Background(), "model-b")
+		result <- err
+	}()
+
+	select {
+	case err := <-result:
+		t.Fatalf("expected request to wait for the switch, returned early with %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(unblock)
+
+	if err := <-switchDone; err != nil {
+		t.Fatalf("expected switch to succeed, got %v", err)
+	}
+	if err := <-result; err != nil {
+		t.Fatalf("expected queued request to be served, got %v", err)
+	}
+}
+
+func TestEnsureActive_QueueFull(t *testing.T) {
+	s, unblock := setupBlockedSwitch(t, WithQueueLimits(1, time.Second))
+	defer close(unblock)
+
+	// The first request triggers the blocked switch and occupies the only slot
+	go s.EnsureActive(context.Background(), "model-b")
+	waitFor(t, func() bool {
+		s.queue.mu.Lock()
+		defer s.queue.mu.Unlock()
+		return len(s.queue.waiters["model-b"]) == 1
+	})
+
+	_, err := s.EnsureActive(context.Background(), "model-b")
+
+	var queueErr *QueueError
+	if !errors.As(err, &queueErr) || queueErr.Reason != "full" {
+		t.Fatalf("expected full QueueError, got %v", err)
+	}
+	if queueErr.RetryAfter <= 0 {
+		t.Error("expected a positive RetryAfter")
+	}
+}
+
+func TestEnsureActive_QueueTimeout(t *testing.T) {
+	s, unblock := setupBlockedSwitch(t, WithQueueLimits(4, 50*time.Millisecond))
+	defer close(unblock)
+
+	_, err := s.EnsureActive(context.Background(), "model-b")
+
+	var queueErr *QueueError
+	if !errors.As(err, &queueErr) || queueErr.Reason != "timeout" {
+		t.Fatalf("expected timeout QueueError, got %v", err)
+	}
+
+	s.queue.mu.Lock()
+	remaining := len(s.queue.waiters["model-b"])
+	s.queue.mu.Unlock()
+	if remaining != 0 {
+		t.Errorf("expected timed out waiter to be removed, %d remain", remaining)
+	}
+}
+
+func TestEnsureActive_SwitchFailureReleasesQueue(t *testing.T) {
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupSleep},
+		},
+	}
+
+	mockClient := vllm.NewMockClient()
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		return host == "vllm-b" || len(mockClient.SleepCalls) > 0, nil
+	}
+	mockClient.WakeUpFunc = func(ctx context.Context, host string, port int) error {
+		if host == "vllm-b" {
+			return errors.New("wake_up failed")
+		}
+		return nil
+	}
+
+	s := NewWithClient(cfg, mockClient, WithMaxRetries(2), WithHealthCheckInterval(10*time.Millisecond))
+	s.WaitForInit()
+
+	_, err := s.EnsureActive(context.Background(), "model-b")
+
+	if !errors.Is(err, ErrModelUnavailable) {
+		t.Fatalf("expected ErrModelUnavailable, got %v", err)
+	}
+}
+
+func TestRequestQueue_Priority(t *testing.T) {
+	q := newRequestQueue(4)
+	q.enqueue("model-a", 1)
+	q.enqueue("model-a", 7)
+	q.enqueue("model-a", 3)
+
+	if p := q.priority("model-a"); p != 7 {
+		t.Errorf("expected the highest waiter priority 7, got %d", p)
+	}
+	if p := q.priority("model-b"); p != 0 {
+		t.Errorf("expected priority 0 without waiters, got %d", p)
+	}
+}
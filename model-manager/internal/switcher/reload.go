@@ -0,0 +1,204 @@
+package switcher
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// Reload applies a new, already validated configuration. Added models are
+// registered, removed models are put to sleep (or have their container stopped)
+// and retired, and every other model picks up its new settings while keeping
+// its status. A model whose container_name or port changed points at another
+// server, so it is retired and added again. The active model is left alone
+// unless it was removed or re-pointed.
+//
+// The reload is rejected, keeping the current configuration, if the models that
+// stay awake would not fit the new GPU memory budget or devices.
+func (s *Switcher) Reload(ctx context.Context, cfg *models.Config) error {
+	added, err := s.applyConfig(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	// Changed schedules apply right away, unless a manual switch overrides them
+	if s.configureSchedules(cfg.Schedules) && s.background {
+		go s.applyCurrentSlot()
+	}
+
+	// Added models start out asleep or disabled; pick up their actual state
+	if len(added) > 0 {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := s.resyncModels(ctx); err != nil {
+			log.Printf("resync after reload warning: %v", err)
+		}
+	}
+	return nil
+}
+
+// Config returns a copy of the current configuration, to build an updated one from
+func (s *Switcher) Config() *models.Config {
+	s.mapMu.RLock()
+	defer s.mapMu.RUnlock()
+
+	cfg := &models.Config{
+		Models:            make([]models.Model, 0, len(s.config.Models)),
+		Queue:             s.config.Queue,
+		CircuitBreaker:    s.config.CircuitBreaker,
+		Thrash:            s.config.Thrash,
+		Schedules:         append([]models.Schedule(nil), s.config.Schedules...),
+		Auth:              models.AuthConfig{Keys: append([]models.APIKey(nil), s.config.Auth.Keys...)},
+		GPUMemoryBudgetGB: s.config.GPUMemoryBudgetGB,
+		GPUs:              append([]models.GPUDevice(nil), s.config.GPUs...),
+	}
+	for i := range s.config.Models {
+		cfg.Models = append(cfg.Models, s.config.Models[i].Snapshot())
+	}
+	return cfg
+}
+
+// applyConfig swaps in the new configuration under the switch lock, so no switch
+// runs against a half-applied config, and returns the IDs of added models
+func (s *Switcher) applyConfig(ctx context.Context, cfg *models.Config) ([]string, error) {
+	s.switchLock.Lock()
+	defer s.switchLock.Unlock()
+
+	s.mapMu.RLock()
+	current := make(map[string]*models.Model, len(s.models))
+	for k, v := range s.models {
+		current[k] = v
+	}
+	s.mapMu.RUnlock()
+
+	incoming := make(map[string]*models.Model, len(cfg.Models))
+	for i := range cfg.Models {
+		incoming[cfg.Models[i].ID] = &cfg.Models[i]
+	}
+
+	// Models that stay awake must fit the new placement constraints
+	var awake []*models.Model
+	for id, m := range current {
+		if next, ok := incoming[id]; ok && !repointed(m, next) && m.GetStatus() == models.StatusActive {
+			awake = append(awake, next)
+		}
+	}
+	if err := s.checkAwakeFit(cfg, awake); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConfigRejected, err)
+	}
+
+	// Free the GPU memory of removed and re-pointed models before retiring them.
+	// Nothing tracks the old server of a re-pointed model once it is replaced.
+	var removed []string
+	for id, m := range current {
+		change := "removed from the config"
+		if next, ok := incoming[id]; ok {
+			if !repointed(m, next) {
+				continue
+			}
+			change = fmt.Sprintf("moved to %s:%d", next.ContainerName, next.Port)
+		} else {
+			removed = append(removed, id)
+		}
+
+		if status := m.GetStatus(); status == models.StatusActive || status == models.StatusError {
+			log.Printf("Model %s was %s, releasing it", id, change)
+			if err := s.releaseModel(ctx, id); err != nil {
+				log.Printf("Warning: failed to release model %s: %v", id, err)
+			}
+		} else {
+			log.Printf("Model %s was %s", id, change)
+		}
+	}
+
+	var added []string
+	next := make(map[string]*models.Model, len(cfg.Models))
+	for i := range cfg.Models {
+		model := &cfg.Models[i]
+		if old, ok := current[model.ID]; ok && !repointed(old, model) {
+			model.InheritState(old)
+		} else {
+			// The model's real state is picked up by the resync that follows
+			if model.StartupMode == models.StartupDisabled {
+				model.MarkDisabled()
+			} else {
+				model.MarkSleeping()
+			}
+			model.SetObserver(s.publishEvent)
+			added = append(added, model.ID)
+			if !ok {
+				log.Printf("Model %s was added to the config", model.ID)
+			}
+		}
+		next[model.ID] = model
+	}
+
+	s.thrash.configure(cfg.Thrash)
+
+	s.mapMu.Lock()
+	s.models = next
+	s.config = cfg
+	active, activeKept := current[s.activeModel]
+	if activeKept {
+		activeKept = !repointed(active, next[s.activeModel])
+	}
+	s.mapMu.Unlock()
+
+	if !activeKept {
+		primary := s.mostRecentlyActive("")
+		s.mapMu.Lock()
+		s.activeModel = primary
+		s.mapMu.Unlock()
+	}
+
+	// Requests waiting for a removed model will never be served
+	for _, id := range removed {
+		s.queue.release(id, fmt.Errorf("%w: %s was removed from the config", ErrModelNotFound, id))
+	}
+
+	log.Printf("Config reloaded: %d models (%d added, %d removed)", len(next), len(added), len(removed))
+	return added, nil
+}
+
+// repointed reports whether a kept model's new config points at a different
+// vLLM server than the running one. next is nil if the model was removed.
+func repointed(old, next *models.Model) bool {
+	return next == nil || next.ContainerName != old.ContainerName || next.Port != old.Port
+}
+
+// checkAwakeFit returns an error if the given awake models, with their new
+// settings, don't fit the configuration's GPU memory budget and devices
+func (s *Switcher) checkAwakeFit(cfg *models.Config, awake []*models.Model) error {
+	if cfg.GPUMemoryBudgetGB <= 0 && len(cfg.GPUs) == 0 {
+		if len(awake) > 1 {
+			return fmt.Errorf("%d models are awake but the new config allows only one active model", len(awake))
+		}
+		return nil
+	}
+
+	if cfg.GPUMemoryBudgetGB > 0 {
+		var used float64
+		for _, m := range awake {
+			used += m.GPUMemoryGB
+		}
+		if used > cfg.GPUMemoryBudgetGB {
+			return fmt.Errorf("awake models need %.1f GB but gpu_memory_budget_gb is %.1f GB", used, cfg.GPUMemoryBudgetGB)
+		}
+	}
+
+	for _, dev := range cfg.GPUs {
+		var used float64
+		for _, m := range awake {
+			if s.usesDevice(m, dev.ID) {
+				used += m.DeviceShareGB(len(cfg.GPUs))
+			}
+		}
+		if used > dev.MemoryGB {
+			return fmt.Errorf("awake models need %.1f GB on gpu %d, which has %.1f GB", used, dev.ID, dev.MemoryGB)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package switcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/internal/vllm"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+func TestReload_AddsAndUpdatesModels(t *testing.T) {
+	s := newStatefulSwitcher(t, stateTestConfig(), newStatefulMock("vllm-b", "vllm-c"))
+
+	next := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive, GPUMemoryGB: 20, IdleTimeout: time.Minute},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupSleep},
+			{ID: "model-c", ContainerName: "vllm-c", Port: 8000, StartupMode: models.StartupSleep},
+		},
+	}
+	if err := s.Reload(context.Background(), next); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	a, _ := s.GetModel("model-a")
+	if a.GetStatus() != models.StatusActive || a.GPUMemoryGB != 20 || a.IdleTimeout != time.Minute {
+		t.Errorf("expected model-a to stay active with new settings, got %s %.0f GB %s", a.GetStatus(), a.GPUMemoryGB, a.IdleTimeout)
+	}
+	if s.GetModels().ActiveModel != "model-a" {
+		t.Errorf("expected the active model to be untouched, got %s", s.GetModels().ActiveModel)
+	}
+
+	c, ok := s.GetModel("model-c")
+	if !ok {
+		t.Fatal("expected model-c to be added")
+	}
+	if c.GetStatus() != models.StatusSleeping {
+		t.Errorf("expected model-c to be sleeping, got %s", c.GetStatus())
+	}
+
+	// The added model can be switched to
+	if err := s.SwitchModel(context.Background(), "model-c"); err != nil {
+		t.Fatalf("expected no error switching to model-c, got %v", err)
+	}
+}
+
+func TestReload_RemovesActiveModel(t *testing.T) {
+	s := newStatefulSwitcher(t, stateTestConfig(), newStatefulMock("vllm-b"))
+	mockClient := s.vllmClient.(*vllm.MockClient)
+
+	next := &models.Config{
+		Models: []models.Model{
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupActive},
+		},
+	}
+	if err := s.Reload(context.Background(), next); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, ok := s.GetModel("model-a"); ok {
+		t.Error("expected model-a to be removed")
+	}
+	if len(mockClient.SleepCalls) != 1 || mockClient.SleepCalls[0].Host != "vllm-a" {
+		t.Errorf("expected removed model-a to be put to sleep, got %+v", mockClient.SleepCalls)
+	}
+	if s.GetModels().ActiveModel != "" {
+		t.Errorf("expected no active model after removing it, got %s", s.GetModels().ActiveModel)
+	}
+
+	if _, err := s.EnsureActive(context.Background(), "model-a"); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("expected ErrModelNotFound for removed model, got %v", err)
+	}
+}
+
+func TestReload_RepointedModelIsReplaced(t *testing.T) {
+	s := newStatefulSwitcher(t, stateTestConfig(), newStatefulMock("vllm-b", "vllm-a2"))
+	mockClient := s.vllmClient.(*vllm.MockClient)
+
+	next := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a2", Port: 8001, StartupMode: models.StartupActive},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupSleep},
+		},
+	}
+	if err := s.Reload(context.Background(), next); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// The old server is put to sleep rather than left holding GPU memory
+	if len(mockClient.SleepCalls) != 1 || mockClient.SleepCalls[0].Host != "vllm-a" {
+		t.Errorf("expected the old model-a server to be put to sleep, got %+v", mockClient.SleepCalls)
+	}
+
+	// The new server was never woken, so it isn't active
+	a, _ := s.GetModel("model-a")
+	if a.GetStatus() != models.StatusSleeping {
+		t.Errorf("expected re-pointed model-a to be sleeping, got %s", a.GetStatus())
+	}
+	if s.GetModels().ActiveModel != "" {
+		t.Errorf("expected no active model after re-pointing it, got %s", s.GetModels().ActiveModel)
+	}
+
+	// Switching to it wakes the new server
+	if err := s.SwitchModel(context.Background(), "model-a"); err != nil {
+		t.Fatalf("expected no error switching to model-a, got %v", err)
+	}
+	if len(mockClient.WakeUpCalls) == 0 || mockClient.WakeUpCalls[len(mockClient.WakeUpCalls)-1].Host != "vllm-a2" {
+		t.Errorf("expected the new model-a server to be woken, got %+v", mockClient.WakeUpCalls)
+	}
+}
+
+func TestReload_RejectsConfigThatDoesNotFitAwakeModels(t *testing.T) {
+	cfg := &models.Config{
+		GPUMemoryBudgetGB: 80,
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive, GPUMemoryGB: 40},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupActive, GPUMemoryGB: 30},
+		},
+	}
+	s := newStatefulSwitcher(t, cfg, newStatefulMock())
+
+	next := &models.Config{
+		GPUMemoryBudgetGB: 60,
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive, GPUMemoryGB: 40},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupActive, GPUMemoryGB: 30},
+		},
+	}
+	if err := s.Reload(context.Background(), next); err == nil {
+		t.Fatal("expected reload to be rejected")
+	}
+
+	if budget := s.GetModels().GPUMemoryBudgetGB; budget != 80 {
+		t.Errorf("expected the old budget to be kept, got %.0f", budget)
+	}
+}
+
+func TestReload_KeepsInFlightRequests(t *testing.T) {
+	cfg := stateTestConfig()
+	cfg.Models[0].IdleTimeout = time.Millisecond
+	s := newStatefulSwitcher(t, cfg, newStatefulMock("vllm-b"))
+
+	done := s.TrackRequest(context.Background(), "model-a")
+
+	next := stateTestConfig()
+	next.Models[0].IdleTimeout = time.Millisecond
+	if err := s.Reload(context.Background(), next); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	s.reapIdleModels(context.Background())
+	if a, _ := s.GetModel("model-a"); a.GetStatus() != models.StatusActive {
+		t.Fatalf("expected model with a request in flight to stay awake, got %s", a.GetStatus())
+	}
+
+	done()
+	time.Sleep(5 * time.Millisecond)
+	s.reapIdleModels(context.Background())
+	if a, _ := s.GetModel("model-a"); a.GetStatus() != models.StatusSleeping {
+		t.Errorf("expected model to sleep once the request finished, got %s", a.GetStatus())
+	}
+}
@@ -0,0 +1,335 @@
+package switcher
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+const (
+	defaultUpcomingFirings = 10
+	maxUpcomingFirings     = 1000
+)
+
+// scheduleLookback is how far back the firing that started the current slot is
+// searched for, in growing windows so frequent schedules stay cheap
+var scheduleLookback = []time.Duration{time.Hour, 24 * time.Hour, 8 * 24 * time.Hour, 32 * 24 * time.Hour, 367 * 24 * time.Hour}
+
+// scheduleContextKey marks switches started by a schedule, which don't override it
+type scheduleContextKey struct{}
+
+// withSchedule marks switches made under ctx as started by the named schedule
+func withSchedule(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, scheduleContextKey{}, name)
+}
+
+// scheduled reports whether ctx carries a switch started by a schedule
+func scheduled(ctx context.Context) bool {
+	_, ok := ctx.Value(scheduleContextKey{}).(string)
+	return ok
+}
+
+// scheduleEntry is a configured schedule with its parsed firing times
+type scheduleEntry struct {
+	cfg   models.Schedule
+	times cron.Schedule
+}
+
+// scheduler fires the configured schedules and tracks the manual switch that
+// overrides them, if any
+type scheduler struct {
+	mu       sync.Mutex
+	configs  []models.Schedule
+	entries  []scheduleEntry
+	cron     *cron.Cron // Fires the entries while background tasks run (nil otherwise)
+	override *models.ScheduleOverride
+}
+
+// newScheduler parses the given schedules. Config validation rejects invalid
+// ones, so any that still fail to parse are logged and skipped.
+func newScheduler(configs []models.Schedule) *scheduler {
+	sc := &scheduler{}
+	sc.setEntries(configs)
+	return sc
+}
+
+// setEntries replaces the schedules; callers hold mu unless sc is new
+func (sc *scheduler) setEntries(configs []models.Schedule) {
+	sc.configs = slices.Clone(configs)
+	sc.entries = nil
+	for _, cfg := range configs {
+		times, err := cfg.Parse()
+		if err != nil {
+			log.Printf("Warning: skipping schedule %s: %v", cfg.Name, err)
+			continue
+		}
+		sc.entries = append(sc.entries, scheduleEntry{cfg: cfg, times: times})
+	}
+}
+
+// start runs fire for each entry whenever it is due, replacing the entries
+// fired so far; callers hold mu
+func (sc *scheduler) start(fire func(models.Schedule)) {
+	if sc.cron != nil {
+		sc.cron.Stop()
+	}
+	sc.cron = cron.New()
+	for _, e := range sc.entries {
+		cfg := e.cfg
+		sc.cron.Schedule(e.times, cron.FuncJob(func() { fire(cfg) }))
+	}
+	sc.cron.Start()
+}
+
+// current returns the most recent firing of any schedule at or before now, which
+// started the slot in effect; callers hold mu
+func (sc *scheduler) current(now time.Time) *models.ScheduleFiring {
+	var latest *models.ScheduleFiring
+	for _, e := range sc.entries {
+		at, ok := previousFiring(e.times, now)
+		if !ok || (latest != nil && !at.After(latest.Time)) {
+			continue
+		}
+		latest = firing(e.cfg, at)
+	}
+	return latest
+}
+
+// upcoming returns the next limit firings of all schedules after now, soonest
+// first; callers hold mu
+func (sc *scheduler) upcoming(now time.Time, limit int) []models.ScheduleFiring {
+	firings := []models.ScheduleFiring{}
+	for _, e := range sc.entries {
+		at := now
+		for range limit {
+			at = e.times.Next(at)
+			if at.IsZero() {
+				break
+			}
+			firings = append(firings, *firing(e.cfg, at))
+		}
+	}
+
+	sort.SliceStable(firings, func(i, j int) bool {
+		return firings[i].Time.Before(firings[j].Time)
+	})
+	if len(firings) > limit {
+		firings = firings[:limit]
+	}
+	return firings
+}
+
+// activeOverride returns the manual switch overriding the schedules, dropping
+// it once the next slot has started; callers hold mu
+func (sc *scheduler) activeOverride(now time.Time) *models.ScheduleOverride {
+	if sc.override != nil && !now.Before(sc.override.Until) {
+		sc.override = nil
+	}
+	return sc.override
+}
+
+// firing describes a schedule firing at the given time
+func firing(cfg models.Schedule, at time.Time) *models.ScheduleFiring {
+	return &models.ScheduleFiring{
+		Schedule: cfg.Name,
+		Action:   cfg.GetAction(),
+		ModelID:  cfg.ModelID,
+		Time:     at,
+	}
+}
+
+// previousFiring returns the last time the schedule fired at or before now, or
+// false if it hasn't within the past year
+func previousFiring(times cron.Schedule, now time.Time) (time.Time, bool) {
+	for _, window := range scheduleLookback {
+		var last time.Time
+		for at := times.Next(now.Add(-window)); !at.IsZero() && !at.After(now); at = times.Next(at) {
+			last = at
+		}
+		if !last.IsZero() {
+			return last, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// startSchedules starts firing the configured schedules
+func (s *Switcher) startSchedules() {
+	s.schedules.mu.Lock()
+	defer s.schedules.mu.Unlock()
+	s.schedules.start(s.fireSchedule)
+}
+
+// configureSchedules applies reloaded schedules and reports whether they changed
+func (s *Switcher) configureSchedules(configs []models.Schedule) bool {
+	s.schedules.mu.Lock()
+	defer s.schedules.mu.Unlock()
+
+	if slices.Equal(configs, s.schedules.configs) {
+		return false
+	}
+	s.schedules.setEntries(configs)
+	if s.schedules.cron != nil {
+		s.schedules.start(s.fireSchedule)
+	}
+	return true
+}
+
+// fireSchedule starts a new slot: any manual override ends and the schedule's
+// action is carried out
+func (s *Switcher) fireSchedule(cfg models.Schedule) {
+	s.schedules.mu.Lock()
+	if s.schedules.override != nil {
+		log.Printf("Schedule %s ends the override by %s", cfg.Name, s.schedules.override.Actor)
+		s.schedules.override = nil
+	}
+	s.schedules.mu.Unlock()
+
+	s.applySchedule(*firing(cfg, time.Now()))
+}
+
+// applyCurrentSlot carries out the action of the slot in effect, unless a manual
+// switch overrides it. It brings the models in line with the schedules on
+// startup and after they change.
+func (s *Switcher) applyCurrentSlot() {
+	now := time.Now()
+	s.schedules.mu.Lock()
+	current := s.schedules.current(now)
+	override := s.schedules.activeOverride(now)
+	s.schedules.mu.Unlock()
+
+	if current == nil {
+		return
+	}
+	if override != nil {
+		log.Printf("Not applying schedule %s: overridden by %s until %s", current.Schedule, override.Actor, override.Until.Format(time.RFC3339))
+		return
+	}
+	if current.Action == models.ScheduleSwitch {
+		if model, ok := s.GetModel(current.ModelID); ok && model.GetStatus() == models.StatusActive {
+			return
+		}
+	}
+
+	log.Printf("Applying schedule %s, in effect since %s", current.Schedule, current.Time.Format(time.RFC3339))
+	s.applySchedule(*current)
+}
+
+// applySchedule switches to the firing schedule's model or puts every model to sleep
+func (s *Switcher) applySchedule(f models.ScheduleFiring) {
+	ctx := withSchedule(WithActor(context.Background(), "schedule:"+f.Schedule), f.Schedule)
+
+	switch f.Action {
+	case models.ScheduleSleep:
+		log.Printf("Schedule %s: putting models to sleep", f.Schedule)
+		s.sleepAll(ctx)
+	default:
+		// A job defers the switch while higher-priority work or a cooldown blocks it
+		job, err := s.StartSwitchJob(ctx, f.ModelID)
+		if err != nil {
+			log.Printf("Schedule %s: failed to switch to %s: %v", f.Schedule, f.ModelID, err)
+			return
+		}
+		log.Printf("Schedule %s: switching to %s (job %s)", f.Schedule, f.ModelID, job.ID)
+	}
+}
+
+// sleepAll puts every awake model to sleep. Models serving requests of a higher
+// priority than ctx's, or still within their min_residency, are left awake.
+// Requests for a model arriving while it goes to sleep are queued.
+func (s *Switcher) sleepAll(ctx context.Context) {
+	defer s.drainQueue()
+
+	s.switchLock.Lock()
+	defer s.switchLock.Unlock()
+
+	ctx = withCause(ctx, models.CauseSchedule)
+	for _, m := range s.awakeModels("") {
+		s.sleepScheduled(ctx, m.ID)
+	}
+
+	next := s.mostRecentlyActive("")
+	s.mapMu.Lock()
+	s.activeModel = next
+	s.mapMu.Unlock()
+}
+
+// sleepScheduled puts one model to sleep for sleepAll, holding only its requests
+// back meanwhile; callers hold switchLock
+func (s *Switcher) sleepScheduled(ctx context.Context, modelID string) {
+	evict := []string{modelID}
+	defer s.holdModels(evict)()
+
+	if err := s.checkResidency(ctx, evict); err != nil {
+		log.Printf("Leaving %s awake: %v", modelID, err)
+		return
+	}
+	if err := s.checkPreemption(ctx, "", evict); err != nil {
+		log.Printf("Leaving %s awake: %v", modelID, err)
+		return
+	}
+
+	err := s.releaseModel(ctx, modelID)
+	if err != nil {
+		log.Printf("Failed to put %s to sleep: %v", modelID, err)
+	}
+	s.auditSleep(ctx, modelID, err)
+}
+
+// overrideSchedules suspends the schedules until the next slot after a manual
+// switch, so they aren't applied over it meanwhile
+func (s *Switcher) overrideSchedules(ctx context.Context, targetModelID string) {
+	actor := actorFrom(ctx)
+	if actor == ActorSystem || scheduled(ctx) {
+		return
+	}
+
+	now := time.Now()
+	s.schedules.mu.Lock()
+	defer s.schedules.mu.Unlock()
+
+	upcoming := s.schedules.upcoming(now, 1)
+	if len(upcoming) == 0 {
+		return
+	}
+	s.schedules.override = &models.ScheduleOverride{
+		Actor:   actor,
+		ModelID: targetModelID,
+		Since:   now,
+		Until:   upcoming[0].Time,
+	}
+	log.Printf("Switch to %s by %s overrides the schedules until %s", targetModelID, actor, upcoming[0].Time.Format(time.RFC3339))
+}
+
+// Schedules returns the configured schedules, the slot in effect, any manual
+// override and the next limit firings (10 if limit is not positive, at most 1000)
+func (s *Switcher) Schedules(limit int) models.SchedulesResponse {
+	if limit <= 0 {
+		limit = defaultUpcomingFirings
+	}
+	limit = min(limit, maxUpcomingFirings)
+
+	now := time.Now()
+	s.schedules.mu.Lock()
+	defer s.schedules.mu.Unlock()
+
+	resp := models.SchedulesResponse{
+		Schedules: slices.Clone(s.schedules.configs),
+		Current:   s.schedules.current(now),
+		Upcoming:  s.schedules.upcoming(now, limit),
+	}
+	if resp.Schedules == nil {
+		resp.Schedules = []models.Schedule{}
+	}
+	if override := s.schedules.activeOverride(now); override != nil {
+		copied := *override
+		resp.Override = &copied
+	}
+	return resp
+}
@@ -0,0 +1,194 @@
+package switcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+func TestScheduler_CurrentAndUpcoming(t *testing.T) {
+	sc := newScheduler([]models.Schedule{
+		{Name: "work", Cron: "0 8 * * 1-5", Timezone: "Europe/Berlin", ModelID: "model-a"},
+		{Name: "evening", Cron: "0 18 * * *", Timezone: "Europe/Berlin", ModelID: "model-b"},
+		{Name: "night", Cron: "30 23 * * *", Timezone: "Europe/Berlin", Action: models.ScheduleSleep},
+	})
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	// Saturday morning: the last firing was Friday night's sleep
+	now := time.Date(2025, 6, 7, 9, 0, 0, 0, berlin)
+	current := sc.current(now)
+	if current == nil || current.Schedule != "night" || !current.Time.Equal(time.Date(2025, 6, 6, 23, 30, 0, 0, berlin)) {
+		t.Fatalf("expected Friday's night slot, got %+v", current)
+	}
+	if current.Action != models.ScheduleSleep {
+		t.Errorf("expected a sleep action, got %s", current.Action)
+	}
+
+	upcoming := sc.upcoming(now, 4)
+	want := []struct {
+		schedule string
+		at       time.Time
+	}{
+		{"evening", time.Date(2025, 6, 7, 18, 0, 0, 0, berlin)},
+		{"night", time.Date(2025, 6, 7, 23, 30, 0, 0, berlin)},
+		{"evening", time.Date(2025, 6, 8, 18, 0, 0, 0, berlin)},
+		{"night", time.Date(2025, 6, 8, 23, 30, 0, 0, berlin)},
+	}
+	if len(upcoming) != len(want) {
+		t.Fatalf("expected %d firings, got %+v", len(want), upcoming)
+	}
+	for i, w := range want {
+		if upcoming[i].Schedule != w.schedule || !upcoming[i].Time.Equal(w.at) {
+			t.Errorf("firing %d: expected %s at %s, got %s at %s", i, w.schedule, w.at, upcoming[i].Schedule, upcoming[i].Time)
+		}
+	}
+}
+
+func TestPreviousFiring_Monthly(t *testing.T) {
+	sched := models.Schedule{Name: "monthly", Cron: "0 3 1 * *", Timezone: "UTC"}
+	times, err := sched.Parse()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	at, ok := previousFiring(times, time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC))
+	if !ok || !at.Equal(time.Date(2025, 3, 1, 3, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the firing on March 1st, got %s (%v)", at, ok)
+	}
+}
+
+func TestApplySchedule_Switch(t *testing.T) {
+	cfg := stateTestConfig()
+	cfg.Schedules = []models.Schedule{{Name: "evening", Cron: "@daily", ModelID: "model-b"}}
+	s := newStatefulSwitcher(t, cfg, newStatefulMock("vllm-b"))
+
+	s.fireSchedule(s.Config().Schedules[0])
+	waitFor(t, func() bool {
+		return s.GetModels().ActiveModel == "model-b"
+	})
+
+	// A scheduled switch doesn't override the schedules
+	if override := s.Schedules(0).Override; override != nil {
+		t.Errorf("expected no override after a scheduled switch, got %+v", override)
+	}
+}
+
+func TestApplySchedule_SleepAll(t *testing.T) {
+	cfg := stateTestConfig()
+	cfg.GPUMemoryBudgetGB = 80
+	cfg.Models[0].GPUMemoryGB = 40
+	cfg.Models[1].GPUMemoryGB = 40
+	cfg.Schedules = []models.Schedule{{Name: "night", Cron: "@daily", Action: models.ScheduleSleep}}
+	s := newStatefulSwitcher(t, cfg, newStatefulMock("vllm-b"))
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// Requests of a higher priority than the schedule's keep their model awake
+	done := s.TrackRequest(WithPriority(context.Background(), 5), "model-b")
+	s.fireSchedule(cfg.Schedules[0])
+
+	if status := s.models["model-a"].GetStatus(); status != models.StatusSleeping {
+		t.Errorf("expected model-a to be put to sleep, got %s", status)
+	}
+	if status := s.models["model-b"].GetStatus(); status != models.StatusActive {
+		t.Errorf("expected busy model-b to stay awake, got %s", status)
+	}
+
+	done()
+	s.fireSchedule(cfg.Schedules[0])
+	if resp := s.GetModels(); len(resp.ActiveModels) != 0 || resp.ActiveModel != "" {
+		t.Errorf("expected every model asleep, got %v (active %q)", resp.ActiveModels, resp.ActiveModel)
+	}
+}
+
+func TestSleepAll_ModelsLeftAwakeKeepServing(t *testing.T) {
+	cfg := stateTestConfig()
+	cfg.GPUMemoryBudgetGB = 80
+	cfg.Queue.MaxWaitSeconds = 1
+	mockClient := newStatefulMock("vllm-b")
+	s := newStatefulSwitcher(t, cfg, mockClient)
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// Putting model-a to sleep takes until unblock is closed
+	unblock := make(chan struct{})
+	sleep := mockClient.SleepFunc
+	mockClient.SleepFunc = func(ctx context.Context, host string, port int, level int) error {
+		if host == "vllm-a" {
+			<-unblock
+		}
+		return sleep(ctx, host, port, level)
+	}
+
+	// Busy model-b stays awake and keeps serving while model-a goes to sleep
+	done := s.TrackRequest(WithPriority(context.Background(), 5), "model-b")
+	defer done()
+	slept := make(chan struct{})
+	go func() {
+		s.sleepAll(context.Background())
+		close(slept)
+	}()
+	waitFor(t, func() bool {
+		return s.models["model-a"].GetStatus() == models.StatusSwitching
+	})
+
+	if _, err := s.EnsureActive(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected model-b to be served during the scheduled sleep, got %v", err)
+	}
+
+	close(unblock)
+	<-slept
+	if status := s.models["model-a"].GetStatus(); status != models.StatusSleeping {
+		t.Errorf("expected model-a to be put to sleep, got %s", status)
+	}
+}
+
+func TestSwitchModel_OverridesSchedulesUntilNextSlot(t *testing.T) {
+	cfg := stateTestConfig()
+	cfg.Schedules = []models.Schedule{{Name: "work", Cron: "0 * * * *", ModelID: "model-a"}}
+	s := newStatefulSwitcher(t, cfg, newStatefulMock("vllm-b"))
+
+	if err := s.SwitchModel(WithActor(context.Background(), "alice"), "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	resp := s.Schedules(0)
+	if resp.Override == nil || resp.Override.Actor != "alice" || resp.Override.ModelID != "model-b" {
+		t.Fatalf("expected alice's switch to override the schedules, got %+v", resp.Override)
+	}
+	if len(resp.Upcoming) == 0 || !resp.Override.Until.Equal(resp.Upcoming[0].Time) {
+		t.Errorf("expected the override to last until the next firing, got %+v", resp.Override)
+	}
+
+	// The slot in effect is not reapplied while overridden
+	s.applyCurrentSlot()
+	time.Sleep(20 * time.Millisecond)
+	if s.GetModels().ActiveModel != "model-b" {
+		t.Fatalf("expected the manual switch to hold, got %s", s.GetModels().ActiveModel)
+	}
+
+	// The next slot ends the override
+	s.fireSchedule(s.Config().Schedules[0])
+	waitFor(t, func() bool {
+		return s.GetModels().ActiveModel == "model-a"
+	})
+	if override := s.Schedules(0).Override; override != nil {
+		t.Errorf("expected the override to end, got %+v", override)
+	}
+}
+
+func TestApplyCurrentSlot(t *testing.T) {
+	cfg := stateTestConfig()
+	cfg.Schedules = []models.Schedule{{Name: "evening", Cron: "* * * * *", ModelID: "model-b"}}
+	s := newStatefulSwitcher(t, cfg, newStatefulMock("vllm-b"))
+
+	s.applyCurrentSlot()
+	waitFor(t, func() bool {
+		return s.GetModels().ActiveModel == "model-b"
+	})
+}
@@ -0,0 +1,126 @@
+package switcher
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// resolveSplitBrain settles which models stay awake after a resync found more of
+// them awake than the configuration allows, for example because bootstrap.sh was
+// run by hand. Awake models are ranked by rankAwake and kept while they fit
+// together; the rest are put to sleep to protect VRAM, with a warning event and
+// metric for each. The top-ranked awake model becomes the primary active model if
+// the current one is no longer awake. prior holds each model's last active time
+// from before the resync, since resync refreshes it for models it finds awake.
+func (s *Switcher) resolveSplitBrain(ctx context.Context, prior map[string]*time.Time) {
+	s.mapMu.RLock()
+	cfg := s.config
+	primary := s.activeModel
+	awake := make([]*models.Model, 0, len(s.models))
+	for _, m := range s.models {
+		if m.GetStatus() == models.StatusActive {
+			awake = append(awake, m)
+		}
+	}
+	s.mapMu.RUnlock()
+
+	s.rankAwake(awake, primary, prior)
+
+	var kept, extra []*models.Model
+	for _, m := range awake {
+		if s.checkAwakeFit(cfg, append(kept, m)) == nil {
+			kept = append(kept, m)
+		} else {
+			extra = append(extra, m)
+		}
+	}
+
+	s.mapMu.Lock()
+	if current, ok := s.models[s.activeModel]; !ok || current.GetStatus() != models.StatusActive {
+		// The primary active model went away; fall back to the best awake one, if any
+		s.activeModel = ""
+		if len(kept) > 0 {
+			s.activeModel = kept[0].ID
+		}
+	}
+	s.mapMu.Unlock()
+
+	if len(extra) == 0 {
+		return
+	}
+
+	// A switch in flight owns placement; the next resync looks again if needed
+	if !s.switchLock.TryLock() {
+		log.Printf("Warning: split brain detected (%s awake alongside %s) while a switch is in flight, deferring",
+			modelIDs(extra), modelIDs(kept))
+		return
+	}
+	defer s.switchLock.Unlock()
+
+	// Sleeping can outlast the resync's deadline
+	ctx = withCause(context.WithoutCancel(ctx), models.CauseSplitBrain)
+	for _, m := range extra {
+		// Recheck now that no switch can be changing it
+		if m.GetStatus() != models.StatusActive {
+			continue
+		}
+
+		message := fmt.Sprintf("model %s was found awake alongside %v, which it does not fit with; putting it to sleep",
+			m.ID, modelIDs(kept))
+		log.Printf("Warning: split brain: %s", message)
+		s.metrics.SplitBrainEviction(m.ID)
+		s.events.Publish(models.StatusEvent{
+			ModelID:   m.ID,
+			OldStatus: models.StatusActive,
+			NewStatus: models.StatusActive,
+			Cause:     models.CauseSplitBrain,
+			Message:   message,
+			Timestamp: time.Now(),
+		})
+
+		err := s.releaseModel(ctx, m.ID)
+		if err != nil {
+			log.Printf("Warning: failed to put %s to sleep after split brain: %v", m.ID, err)
+		}
+		s.auditSplitBrain(m.ID, modelIDs(kept), err)
+	}
+}
+
+// rankAwake orders awake models by how strongly they should stay awake: models
+// persisted as active before a restart first (only set until the initial resync
+// is reconciled), then the primary active model, then the most recently used,
+// then models configured to start active, then by ID
+func (s *Switcher) rankAwake(awake []*models.Model, primary string, prior map[string]*time.Time) {
+	s.stateMu.Lock()
+	restored := make(map[string]bool, len(s.restoredActive))
+	for _, id := range s.restoredActive {
+		restored[id] = true
+	}
+	s.stateMu.Unlock()
+
+	sort.Slice(awake, func(i, j int) bool {
+		a, b := awake[i], awake[j]
+		if restored[a.ID] != restored[b.ID] {
+			return restored[a.ID]
+		}
+		if (a.ID == primary) != (b.ID == primary) {
+			return a.ID == primary
+		}
+		ta, tb := prior[a.ID], prior[b.ID]
+		switch {
+		case ta != nil && tb != nil && !ta.Equal(*tb):
+			return ta.After(*tb)
+		case (ta == nil) != (tb == nil):
+			return ta != nil
+		}
+		if (a.StartupMode == models.StartupActive) != (b.StartupMode == models.StartupActive) {
+			return a.StartupMode == models.StartupActive
+		}
+		return a.ID < b.ID
+	})
+}
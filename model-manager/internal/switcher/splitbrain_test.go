@@ -0,0 +1,120 @@
+package switcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/internal/state"
+	"github.com/zheng/homeGPT/internal/system"
+	"github.com/zheng/homeGPT/internal/vllm"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// sleptHosts returns the hosts the mock was asked to put to sleep
+func sleptHosts(mockClient *vllm.MockClient) []string {
+	var hosts []string
+	for _, call := range mockClient.SleepCalls {
+		hosts = append(hosts, call.Host)
+	}
+	return hosts
+}
+
+func TestResync_SplitBrainKeepsPrimaryModel(t *testing.T) {
+	// model-b was woken out of band while model-a is the active model
+	mockClient := newStatefulMock()
+	s := NewWithClient(stateTestConfig(), mockClient,
+		WithoutBackgroundTasks(),
+		WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 128}))
+
+	ch, cancel := s.Events().Subscribe()
+	defer cancel()
+
+	s.resyncModels(context.Background())
+
+	if hosts := sleptHosts(mockClient); len(hosts) != 1 || hosts[0] != "vllm-b" {
+		t.Fatalf("expected only vllm-b to be put to sleep, got %v", hosts)
+	}
+	if status := s.models["model-a"].GetStatus(); status != models.StatusActive {
+		t.Errorf("expected model-a to stay active, got %s", status)
+	}
+	if status := s.models["model-b"].GetStatus(); status != models.StatusSleeping {
+		t.Errorf("expected model-b to be asleep, got %s", status)
+	}
+	if s.GetModels().ActiveModel != "model-a" {
+		t.Errorf("expected model-a to stay the active model, got %s", s.GetModels().ActiveModel)
+	}
+
+	var warned bool
+	for _, event := range collectEvents(ch) {
+		if event.Cause == models.CauseSplitBrain && event.ModelID == "model-b" && event.Message != "" {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Error("expected a split brain warning event for model-b")
+	}
+}
+
+func TestResync_SplitBrainPrefersMostRecentlyUsed(t *testing.T) {
+	mockClient := newStatefulMock()
+	s := NewWithClient(stateTestConfig(), mockClient,
+		WithoutBackgroundTasks(),
+		WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 128}))
+
+	// Neither is the primary model; model-b served a request more recently
+	earlier := time.Now().Add(-time.Hour)
+	later := time.Now().Add(-time.Minute)
+	s.models["model-a"].SetLastActive(&earlier)
+	s.models["model-b"].SetLastActive(&later)
+	s.activeModel = ""
+
+	s.resyncModels(context.Background())
+
+	if hosts := sleptHosts(mockClient); len(hosts) != 1 || hosts[0] != "vllm-a" {
+		t.Fatalf("expected only vllm-a to be put to sleep, got %v", hosts)
+	}
+	if s.GetModels().ActiveModel != "model-b" {
+		t.Errorf("expected model-b to become the active model, got %s", s.GetModels().ActiveModel)
+	}
+}
+
+func TestResync_SplitBrainPrefersPersistedActiveModel(t *testing.T) {
+	store := state.NewMemory()
+	store.SaveActiveModels([]string{"model-b"})
+
+	// After a restart both servers are awake, model-a by its startup_mode
+	mockClient := newStatefulMock()
+	s := NewWithClient(stateTestConfig(), mockClient,
+		WithStateStore(store),
+		WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 128}),
+		WithMaxRetries(2),
+		WithHealthCheckInterval(10*time.Millisecond))
+	s.WaitForInit()
+
+	if hosts := sleptHosts(mockClient); len(hosts) != 1 || hosts[0] != "vllm-a" {
+		t.Fatalf("expected only vllm-a to be put to sleep, got %v", hosts)
+	}
+	if s.GetModels().ActiveModel != "model-b" {
+		t.Errorf("expected the persisted model-b to stay active, got %s", s.GetModels().ActiveModel)
+	}
+}
+
+func TestResync_AwakeModelsWithinBudget(t *testing.T) {
+	cfg := stateTestConfig()
+	cfg.GPUMemoryBudgetGB = 80
+	cfg.Models[0].GPUMemoryGB = 40
+	cfg.Models[1].GPUMemoryGB = 30
+
+	mockClient := newStatefulMock()
+	s := NewWithClient(cfg, mockClient, WithoutBackgroundTasks())
+
+	s.resyncModels(context.Background())
+
+	if len(mockClient.SleepCalls) != 0 {
+		t.Errorf("expected models that fit the budget to stay awake, got sleeps for %v", sleptHosts(mockClient))
+	}
+	if active := s.GetModels().ActiveModels; len(active) != 2 {
+		t.Errorf("expected both models awake, got %v", active)
+	}
+}
@@ -0,0 +1,151 @@
+package switcher
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/zheng/homeGPT/internal/state"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// WithStateStore persists the active models, last-active times, error counts and
+// switch history in the given store, and restores them on startup
+func WithStateStore(store state.Store) Option {
+	return func(s *Switcher) {
+		s.store = store
+	}
+}
+
+// restoreState loads persisted per-model state and remembers which models were
+// active, to be reconciled with the vLLM servers once the initial resync is done
+func (s *Switcher) restoreState() {
+	st, err := s.store.Load()
+	if err != nil {
+		log.Printf("Warning: failed to load persisted state, starting fresh: %v", err)
+		return
+	}
+
+	s.stateMu.Lock()
+	for id, ms := range st.Models {
+		model, ok := s.models[id]
+		if !ok {
+			continue
+		}
+		if ms.LastActive != nil {
+			model.SetLastActive(ms.LastActive)
+		}
+		s.errorCounts[id] = ms.ErrorCount
+	}
+	s.restoredActive = st.ActiveModels
+	s.stateMu.Unlock()
+
+	if len(st.ActiveModels) > 0 {
+		log.Printf("Restored state: previously active models %v", st.ActiveModels)
+	}
+}
+
+// reconcileState switches back to the models that were active before the restart
+// when resync found them asleep, e.g. because the stack came back up with the
+// startup_mode defaults
+func (s *Switcher) reconcileState(ctx context.Context) {
+	s.stateMu.Lock()
+	restored := s.restoredActive
+	s.restoredActive = nil
+	s.stateMu.Unlock()
+
+	// Least recently used first, so the last one restored is the most recent
+	for _, id := range restored {
+		s.mapMu.RLock()
+		model, ok := s.models[id]
+		s.mapMu.RUnlock()
+
+		if !ok {
+			log.Printf("Previously active model %s is no longer configured", id)
+			continue
+		}
+
+		status := model.GetStatus()
+		switch {
+		case status == models.StatusActive:
+			s.mapMu.Lock()
+			s.activeModel = id
+			s.mapMu.Unlock()
+			continue
+		case status == models.StatusDisabled && !s.startsOnDemand(model):
+			log.Printf("Not restoring previously active model %s: it is disabled", id)
+			continue
+		}
+
+		log.Printf("Restoring previously active model %s (currently %s)", id, status)
+		if err := s.SwitchModel(ctx, id); err != nil {
+			log.Printf("Failed to restore previously active model %s: %v", id, err)
+		}
+	}
+}
+
+// persistEvent saves the state affected by a model status change
+func (s *Switcher) persistEvent(event models.StatusEvent) {
+	s.mapMu.RLock()
+	model, ok := s.models[event.ModelID]
+	s.mapMu.RUnlock()
+	if !ok {
+		return
+	}
+
+	wasActive := event.OldStatus == models.StatusActive
+	isActive := event.NewStatus == models.StatusActive
+
+	s.stateMu.Lock()
+	if event.NewStatus == models.StatusError {
+		s.errorCounts[event.ModelID]++
+	}
+	errorCount := s.errorCounts[event.ModelID]
+	s.stateMu.Unlock()
+
+	// Leaving active captures the last request time; entering it the wake time
+	if wasActive || isActive || event.NewStatus == models.StatusError {
+		ms := state.ModelState{LastActive: model.GetLastActive(), ErrorCount: errorCount}
+		if err := s.store.SaveModel(event.ModelID, ms); err != nil {
+			log.Printf("Warning: failed to persist state of model %s: %v", event.ModelID, err)
+		}
+	}
+
+	if wasActive || isActive {
+		if err := s.store.SaveActiveModels(modelIDs(s.awakeModels(""))); err != nil {
+			log.Printf("Warning: failed to persist active models: %v", err)
+		}
+	}
+}
+
+// modelIDs returns the IDs of the given models
+func modelIDs(list []*models.Model) []string {
+	ids := make([]string, len(list))
+	for i, m := range list {
+		ids[i] = m.ID
+	}
+	return ids
+}
+
+// recordSwitch appends a finished switch to the persistent history
+func (s *Switcher) recordSwitch(targetModelID string, from []string, started time.Time, err error) {
+	record := models.SwitchRecord{
+		ModelID:    targetModelID,
+		From:       from,
+		StartedAt:  started,
+		FinishedAt: time.Now(),
+		Success:    err == nil,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	if err := s.store.AppendSwitch(record); err != nil {
+		log.Printf("Warning: failed to persist switch to %s: %v", targetModelID, err)
+	}
+}
+
+// SwitchHistory returns up to limit completed switches, newest first
+func (s *Switcher) SwitchHistory(limit int) ([]models.SwitchRecord, error) {
+	return s.store.History(limit)
+}
@@ -0,0 +1,164 @@
+package switcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/internal/state"
+	"github.com/zheng/homeGPT/internal/system"
+	"github.com/zheng/homeGPT/internal/vllm"
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// newStatefulMock returns a mock vLLM client whose sleep state follows Sleep and
+// WakeUp calls, starting with the given hosts asleep
+func newStatefulMock(asleep ...string) *vllm.MockClient {
+	var mu sync.Mutex
+	sleeping := make(map[string]bool)
+	for _, host := range asleep {
+		sleeping[host] = true
+	}
+
+	mockClient := vllm.NewMockClient()
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return sleeping[host], nil
+	}
+	mockClient.SleepFunc = func(ctx context.Context, host string, port int, level int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sleeping[host] = true
+		return nil
+	}
+	mockClient.WakeUpFunc = func(ctx context.Context, host string, port int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sleeping[host] = false
+		return nil
+	}
+	return mockClient
+}
+
+// newStatefulSwitcher creates a switcher without background tasks around a mock
+// such as newStatefulMock, with plenty of RAM and fast health checks
+func newStatefulSwitcher(t *testing.T, cfg *models.Config, mockClient *vllm.MockClient, opts ...Option) *Switcher {
+	t.Helper()
+
+	opts = append([]Option{
+		WithoutBackgroundTasks(),
+		WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 128}),
+		WithMaxRetries(2),
+		WithHealthCheckInterval(10 * time.Millisecond),
+	}, opts...)
+	return NewWithClient(cfg, mockClient, opts...)
+}
+
+func stateTestConfig() *models.Config {
+	return &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupSleep},
+		},
+	}
+}
+
+func TestNew_RestoresPersistedState(t *testing.T) {
+	store := state.NewMemory()
+	lastActive := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	store.SaveActiveModels([]string{"model-b"})
+	store.SaveModel("model-b", state.ModelState{LastActive: &lastActive, ErrorCount: 3})
+
+	s := NewWithClient(stateTestConfig(), newStatefulMock("vllm-b"),
+		WithoutBackgroundTasks(),
+		WithStateStore(store))
+
+	got := s.models["model-b"].GetLastActive()
+	if got == nil || !got.Equal(lastActive) {
+		t.Errorf("expected last active %v to be restored, got %v", lastActive, got)
+	}
+	if s.errorCounts["model-b"] != 3 {
+		t.Errorf("expected error count 3 to be restored, got %d", s.errorCounts["model-b"])
+	}
+}
+
+func TestNew_ReconcilesPersistedActiveModel(t *testing.T) {
+	store := state.NewMemory()
+	store.SaveActiveModels([]string{"model-b"})
+
+	// The stack restarted with startup_mode defaults: model-a awake, model-b asleep
+	s := NewWithClient(stateTestConfig(), newStatefulMock("vllm-b"),
+		WithStateStore(store),
+		WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 128}),
+		WithMaxRetries(2),
+		WithHealthCheckInterval(10*time.Millisecond))
+	s.WaitForInit()
+
+	resp := s.GetModels()
+	if resp.ActiveModel != "model-b" {
+		t.Errorf("expected model-b to be restored as active, got %s", resp.ActiveModel)
+	}
+	if status := s.models["model-a"].GetStatus(); status != models.StatusSleeping {
+		t.Errorf("expected model-a to be put to sleep, got %s", status)
+	}
+
+	st, _ := store.Load()
+	if len(st.ActiveModels) != 1 || st.ActiveModels[0] != "model-b" {
+		t.Errorf("expected persisted active models [model-b], got %v", st.ActiveModels)
+	}
+}
+
+func TestSwitchModel_PersistsStateAndHistory(t *testing.T) {
+	store := state.NewMemory()
+	mockClient := newStatefulMock("vllm-b")
+
+	s := NewWithClient(stateTestConfig(), mockClient,
+		WithoutBackgroundTasks(),
+		WithStateStore(store),
+		WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 128}),
+		WithMaxRetries(2),
+		WithHealthCheckInterval(10*time.Millisecond))
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	wakeUp := mockClient.WakeUpFunc
+	mockClient.WakeUpFunc = func(ctx context.Context, host string, port int) error {
+		if host == "vllm-a" {
+			return errors.New("wake failed")
+		}
+		return wakeUp(ctx, host, port)
+	}
+	if err := s.SwitchModel(context.Background(), "model-a"); err == nil {
+		t.Fatal("expected switch to model-a to fail")
+	}
+
+	st, _ := store.Load()
+	if len(st.ActiveModels) != 1 || st.ActiveModels[0] != "model-b" {
+		t.Errorf("expected persisted active models [model-b] after rollback, got %v", st.ActiveModels)
+	}
+	if st.Models["model-b"].LastActive == nil {
+		t.Error("expected model-b last active to be persisted")
+	}
+	if st.Models["model-a"].ErrorCount != 1 {
+		t.Errorf("expected model-a error count 1, got %d", st.Models["model-a"].ErrorCount)
+	}
+
+	history, err := s.SwitchHistory(0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 switch records, got %d", len(history))
+	}
+	if history[0].ModelID != "model-a" || history[0].Success || history[0].Error == "" {
+		t.Errorf("expected failed switch to model-a first, got %+v", history[0])
+	}
+	if history[1].ModelID != "model-b" || !history[1].Success || len(history[1].From) != 1 || history[1].From[0] != "model-a" {
+		t.Errorf("expected successful switch from model-a to model-b, got %+v", history[1])
+	}
+}
@@ -2,15 +2,26 @@ package switcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/zheng/homeGPT/internal/audit"
+	"github.com/zheng/homeGPT/internal/events"
+	"github.com/zheng/homeGPT/internal/metrics"
+	"github.com/zheng/homeGPT/internal/runtime"
+	"github.com/zheng/homeGPT/internal/state"
 	"github.com/zheng/homeGPT/internal/system"
+	"github.com/zheng/homeGPT/internal/tracing"
 	"github.com/zheng/homeGPT/internal/utils"
 	"github.com/zheng/homeGPT/internal/vllm"
 	"github.com/zheng/homeGPT/pkg/models"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Switcher manages model switching operations
@@ -18,15 +29,54 @@ type Switcher struct {
 	config              *models.Config
 	vllmClient          vllm.VLLMClient
 	ramFetcher          system.RAMFetcher
+	containers          runtime.ContainerRuntime // Starts/stops containers on demand (nil = disabled models stay off)
 	models              map[string]*models.Model
 	activeModel         string
 	healthCheckInterval time.Duration
 	maxRetries          int
-	mapMu               sync.RWMutex   // Protects models map and activeModel string only
-	switchLock          sync.Mutex     // Ensures only one switch operation at a time
-	initSync            sync.WaitGroup // Tracks initial resync completion
+	queue               *requestQueue    // Inference requests waiting for their model
+	queueMaxWait        time.Duration    // How long a queued request waits before giving up
+	jobs                *jobStore        // Recent asynchronous switch jobs
+	events              *events.Bus      // Publishes model status changes
+	metrics             *metrics.Metrics // Prometheus metrics (nil = disabled)
+	store               state.Store      // Persists state across restarts
+	audit               *audit.Log       // Records switches and resync corrections (nil = disabled)
+	errorCounts         map[string]int   // Transitions into error per model
+	restoredActive      []string         // Persisted active models awaiting reconciliation
+	queueHeldUntil      time.Time        // Queued requests don't start switches before this, during a cooldown
+	stateMu             sync.Mutex       // Protects errorCounts, restoredActive and queueHeldUntil
+	idleCheckInterval   time.Duration    // How often the idle reaper looks for idle models
+	switchesInFlight    atomic.Int32     // Switches waiting for or holding switchLock
+	running             *runningSwitch   // Switch holding switchLock, for cancellation
+	held                []string         // Models the switch or sleep holding switchLock is changing
+	runningMu           sync.Mutex       // Protects running and held
+	workDone            chan struct{}    // Closed when an inference request finishes, for deferred switches
+	workMu              sync.Mutex       // Protects workDone
+	thrash              *thrashDetector  // Counts switches to hold them back when they alternate too fast
+	schedules           *scheduler       // Switches models or puts them to sleep at set times
+	background          bool             // Run resync and idle reaper goroutines
+	mapMu               sync.RWMutex     // Protects models map, activeModel string and config pointer
+	switchLock          sync.Mutex       // Ensures only one switch operation at a time
+	initSync            sync.WaitGroup   // Tracks initial resync completion
 }
 
+var (
+	// ErrModelNotFound is returned when a model ID is not in the configuration
+	ErrModelNotFound = errors.New("model not found")
+	// ErrModelUnavailable is returned when a model cannot serve requests
+	ErrModelUnavailable = errors.New("model unavailable")
+	// ErrConfigRejected is returned when a reloaded config conflicts with the models that are awake
+	ErrConfigRejected = errors.New("config rejected")
+	// ErrSwitchCanceled is the cause of a switch aborted by CancelSwitch or CancelSwitchJob
+	ErrSwitchCanceled = errors.New("switch canceled")
+	// ErrNoSwitchInFlight is returned by CancelSwitch when no switch is running
+	ErrNoSwitchInFlight = errors.New("no switch in progress")
+	// ErrJobNotFound is returned when a switch job ID is unknown or has aged out
+	ErrJobNotFound = errors.New("switch job not found")
+	// ErrJobFinished is returned when canceling a switch job that already finished
+	ErrJobFinished = errors.New("switch job already finished")
+)
+
 const (
 	defaultHealthCheckInterval = 2 * time.Second
 	defaultMaxRetries          = 450 // 15 minutes max startup time (450 * 2s = 900s)
@@ -49,6 +99,47 @@ func WithMaxRetries(retries int) Option {
 	}
 }
 
+// WithQueueLimits sets the maximum queue depth per model and the maximum queue wait
+func WithQueueLimits(maxDepth int, maxWait time.Duration) Option {
+	return func(s *Switcher) {
+		s.queue = newRequestQueue(maxDepth)
+		s.queueMaxWait = maxWait
+	}
+}
+
+// WithIdleCheckInterval sets how often idle models are checked for auto-sleep
+func WithIdleCheckInterval(interval time.Duration) Option {
+	return func(s *Switcher) {
+		s.idleCheckInterval = interval
+	}
+}
+
+// WithoutBackgroundTasks skips the startup resync, periodic resync and idle reaper,
+// for one-shot commands such as bootstrap that drive model state themselves
+func WithoutBackgroundTasks() Option {
+	return func(s *Switcher) {
+		s.background = false
+	}
+}
+
+// WithMetrics records switch and model telemetry in the given metrics
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(s *Switcher) {
+		s.metrics = m
+	}
+}
+
+// WithCircuitBreaker wraps the vLLM client in a circuit breaker per server, so
+// calls to a crashed container fail fast instead of waiting out their timeouts.
+// It is configured by the circuit_breaker section of the config; changes to that
+// section take effect on restart.
+func WithCircuitBreaker() Option {
+	return func(s *Switcher) {
+		cb := s.config.CircuitBreaker
+		s.vllmClient = vllm.NewBreaker(s.vllmClient, cb.FailureThreshold, time.Duration(cb.OpenSeconds)*time.Second)
+	}
+}
+
 // WithRAMFetcher sets a custom RAM fetcher for testing
 func WithRAMFetcher(fetcher system.RAMFetcher) Option {
 	return func(s *Switcher) {
@@ -70,6 +161,23 @@ func NewWithClient(cfg *models.Config, client vllm.VLLMClient, opts ...Option) *
 		models:              make(map[string]*models.Model),
 		healthCheckInterval: defaultHealthCheckInterval,
 		maxRetries:          defaultMaxRetries,
+		queue:               newRequestQueue(defaultQueueMaxDepth),
+		queueMaxWait:        defaultQueueMaxWait,
+		jobs:                newJobStore(defaultJobHistory),
+		events:              events.NewBus(),
+		store:               state.NewMemory(),
+		errorCounts:         make(map[string]int),
+		idleCheckInterval:   defaultIdleCheckInterval,
+		thrash:              &thrashDetector{cfg: cfg.Thrash},
+		schedules:           newScheduler(cfg.Schedules),
+		background:          true,
+	}
+
+	if cfg.Queue.MaxDepth > 0 {
+		s.queue = newRequestQueue(cfg.Queue.MaxDepth)
+	}
+	if cfg.Queue.MaxWaitSeconds > 0 {
+		s.queueMaxWait = time.Duration(cfg.Queue.MaxWaitSeconds) * time.Second
 	}
 
 	// Apply options
@@ -88,15 +196,32 @@ func NewWithClient(cfg *models.Config, client vllm.VLLMClient, opts ...Option) *
 			model.MarkSleeping()
 		case models.StartupActive:
 			model.MarkActive()
-			s.activeModel = model.ID
+			if s.activeModel == "" {
+				s.activeModel = model.ID
+			}
 		default:
 			log.Fatalf("Invalid startup_mode '%s' for model %s. Must be 'disabled', 'sleep', or 'active'",
 				model.StartupMode, model.ID)
 		}
 
+		model.SetObserver(s.publishEvent)
 		s.models[model.ID] = model
 	}
 
+	s.restoreState()
+
+	s.metrics.RegisterModels(func() []models.Model { return s.GetModels().Models })
+
+	if s.background {
+		s.startBackgroundTasks()
+	}
+
+	return s
+}
+
+// startBackgroundTasks resyncs with the vLLM servers and starts the periodic
+// resync and idle reaper goroutines
+func (s *Switcher) startBackgroundTasks() {
 	// Perform an initial resync with the vLLM servers to ensure in-memory
 	// state matches actual server state (containers may have restarted).
 	s.initSync.Add(1)
@@ -119,6 +244,8 @@ func NewWithClient(cfg *models.Config, client vllm.VLLMClient, opts ...Option) *
 
 		if err == nil {
 			log.Printf("initial resync completed successfully")
+			s.reconcileState(ctx)
+			s.applyCurrentSlot()
 		} else {
 			log.Printf("initial resync failed after %d attempts: %v", cfg.MaxAttempts, err)
 		}
@@ -137,26 +264,45 @@ func NewWithClient(cfg *models.Config, client vllm.VLLMClient, opts ...Option) *
 		}
 	}()
 
-	return s
+	// Put models to sleep once their idle_timeout elapses without traffic
+	go s.runIdleReaper()
+
+	// Switch models or put them to sleep at the configured times
+	s.startSchedules()
+
 }
 
 // resyncModels queries each configured vLLM endpoint and updates the in-memory
 // model statuses to reflect the actual server state. This helps recover from
 // container restarts or out-of-band changes.
 func (s *Switcher) resyncModels(ctx context.Context) error {
+	ctx = withCause(ctx, models.CauseResync)
+
 	s.mapMu.RLock()
 	modelsCopy := make(map[string]*models.Model, len(s.models))
+	prior := make(map[string]*time.Time, len(s.models))
 	for k, v := range s.models {
 		modelsCopy[k] = v
+		prior[k] = v.GetLastActive()
 	}
 	s.mapMu.RUnlock()
 
-	var lastActive string
 	var anyErr error
 
 	for id, m := range modelsCopy {
-		// Skip disabled models
-		if m.StartupMode == models.StartupDisabled {
+		// Skip disabled models, unless their container may have been started on demand
+		if s.startsOnDemand(m) {
+			running, err := s.resyncContainer(ctx, m)
+			if err != nil {
+				anyErr = err
+				s.metrics.ResyncError(id)
+				log.Printf("resync: %v", err)
+				continue
+			}
+			if !running {
+				continue
+			}
+		} else if m.StartupMode == models.StartupDisabled {
 			continue
 		}
 
@@ -166,29 +312,23 @@ func (s *Switcher) resyncModels(ctx context.Context) error {
 			// mark as error but continue
 			m.MarkError()
 			anyErr = err
+			s.metrics.ResyncError(id)
 			log.Printf("resync: failed to query %s (%s:%d): %v", id, m.ContainerName, m.Port, err)
 			continue
 		}
 
 		if sleeping {
-			m.MarkSleeping()
+			s.mark(ctx, m, models.StatusSleeping)
 		} else {
-			m.MarkActive()
-			// record the last active model (if multiple awake, first wins)
-			if lastActive == "" {
-				lastActive = id
+			// Only refresh lastActive on a state change so resync doesn't reset idle timers
+			if m.GetStatus() != models.StatusActive {
+				s.mark(ctx, m, models.StatusActive)
 			}
 		}
 	}
 
-	s.mapMu.Lock()
-	if lastActive != "" {
-		s.activeModel = lastActive
-	} else {
-		// no model reported active; clear activeModel
-		s.activeModel = ""
-	}
-	s.mapMu.Unlock()
+	// Settle the primary active model, and models awake that shouldn't be
+	s.resolveSplitBrain(ctx, prior)
 
 	return anyErr
 }
@@ -199,27 +339,250 @@ func (s *Switcher) GetModels() models.ModelsResponse {
 	defer s.mapMu.RUnlock()
 
 	modelList := make([]models.Model, 0, len(s.models))
-	for _, m := range s.models {
+	activeModels := make([]string, 0, len(s.models))
+	awake := make([]*models.Model, 0, len(s.models))
+	var usedGB float64
+	for id, m := range s.models {
 		modelList = append(modelList, m.Snapshot())
+		if m.GetStatus() == models.StatusActive {
+			activeModels = append(activeModels, id)
+			awake = append(awake, m)
+			usedGB += m.GPUMemoryGB
+		}
 	}
+	sort.Strings(activeModels)
 
 	return models.ModelsResponse{
-		Models:      modelList,
-		ActiveModel: s.activeModel,
+		Models:               modelList,
+		ActiveModel:          s.activeModel,
+		ActiveModels:         activeModels,
+		GPUMemoryBudgetGB:    s.config.GPUMemoryBudgetGB,
+		GPUMemoryUsedGB:      usedGB,
+		Devices:              s.deviceUsage(awake),
+		Circuits:             s.circuits(),
+		SwitchBackoffSeconds: int64(math.Ceil(s.thrash.backoffRemaining(time.Now()).Seconds())),
+	}
+}
+
+// circuits reports the circuit breaker of every model's server, or nil if the
+// vLLM client has no breaker. Callers must hold mapMu.
+func (s *Switcher) circuits() map[string]models.CircuitStatus {
+	reporter, ok := s.vllmClient.(vllm.CircuitReporter)
+	if !ok {
+		return nil
+	}
+
+	circuits := make(map[string]models.CircuitStatus, len(s.models))
+	for id, m := range s.models {
+		circuits[id] = reporter.Circuit(m.ContainerName, m.Port)
+	}
+	return circuits
+}
+
+// GetModel returns a snapshot of a single model by ID
+func (s *Switcher) GetModel(modelID string) (models.Model, bool) {
+	s.mapMu.RLock()
+	model, exists := s.models[modelID]
+	s.mapMu.RUnlock()
+
+	if !exists {
+		return models.Model{}, false
 	}
+	return model.Snapshot(), true
 }
 
-// WaitForInit waits for the initial resync to complete
+// WaitForInit waits for the initial resync, and the restore of the previously
+// active models, to complete
 func (s *Switcher) WaitForInit() {
 	s.initSync.Wait()
 }
 
-// SwitchModel switches from the current active model to the target model
+// EnsureActive makes sure a model is active before it is used for inference.
+// Requests for a model that is not active, or that a switch in flight is putting
+// to sleep or waking, wait in a bounded per-model FIFO queue. A sleeping model is switched
+// in on demand and its queue drains once it is healthy. Models that are
+// disabled or in an error state are not touched.
+func (s *Switcher) EnsureActive(ctx context.Context, modelID string) (models.Model, error) {
+	s.mapMu.RLock()
+	model, exists := s.models[modelID]
+	s.mapMu.RUnlock()
+
+	if !exists {
+		return models.Model{}, fmt.Errorf("%w: %s", ErrModelNotFound, modelID)
+	}
+
+	switch status := model.GetStatus(); status {
+	case models.StatusActive:
+		if !s.isHeld(modelID) {
+			model.Touch()
+			return model.Snapshot(), nil
+		}
+	case models.StatusSleeping, models.StatusSwitching:
+		log.Printf("Model %s requested while %s, queueing until it is active", modelID, status)
+	case models.StatusDisabled:
+		if !s.startsOnDemand(model) {
+			return models.Model{}, fmt.Errorf("%w: model %s is %s", ErrModelUnavailable, modelID, status)
+		}
+		log.Printf("Model %s requested while its container is stopped, queueing until it is started", modelID)
+	default:
+		return models.Model{}, fmt.Errorf("%w: model %s is %s", ErrModelUnavailable, modelID, status)
+	}
+
+	w, err := s.queue.enqueue(modelID, priorityFrom(ctx))
+	if err != nil {
+		return models.Model{}, err
+	}
+
+	// Start a switch for the queue unless one is already running
+	s.drainQueue()
+
+	timer := time.NewTimer(s.queueMaxWait)
+	defer timer.Stop()
+
+	select {
+	case err := <-w.done:
+		if err != nil {
+			return models.Model{}, err
+		}
+	case <-timer.C:
+		s.queue.remove(modelID, w)
+		return models.Model{}, &QueueError{ModelID: modelID, Reason: "timeout", RetryAfter: queueRetryAfter}
+	case <-ctx.Done():
+		s.queue.remove(modelID, w)
+		return models.Model{}, ctx.Err()
+	}
+
+	// A reload may have replaced or removed the model while the request waited
+	s.mapMu.RLock()
+	model, exists = s.models[modelID]
+	s.mapMu.RUnlock()
+
+	if !exists {
+		return models.Model{}, fmt.Errorf("%w: %s", ErrModelNotFound, modelID)
+	}
+	if status := model.GetStatus(); status != models.StatusActive {
+		return models.Model{}, fmt.Errorf("%w: model %s is %s", ErrModelUnavailable, modelID, status)
+	}
+	model.Touch()
+	return model.Snapshot(), nil
+}
+
+// drainQueue releases requests whose model is active and starts a switch for the
+// model that has waited longest. Models a switch in flight is changing are left
+// queued, and no other switch starts meanwhile; that switch drains the queue
+// again when it finishes.
+func (s *Switcher) drainQueue() {
+	for _, id := range s.queue.modelIDs() {
+		if s.isHeld(id) {
+			continue
+		}
+
+		s.mapMu.RLock()
+		model, exists := s.models[id]
+		s.mapMu.RUnlock()
+
+		if !exists {
+			s.queue.release(id, fmt.Errorf("%w: %s", ErrModelNotFound, id))
+			continue
+		}
+
+		switch status := model.GetStatus(); status {
+		case models.StatusActive:
+			s.queue.release(id, nil)
+		case models.StatusSleeping, models.StatusSwitching:
+			// Needs a switch
+		case models.StatusDisabled:
+			if !s.startsOnDemand(model) {
+				s.queue.release(id, fmt.Errorf("%w: model %s is %s", ErrModelUnavailable, id, status))
+			}
+		default:
+			s.queue.release(id, fmt.Errorf("%w: model %s is %s", ErrModelUnavailable, id, status))
+		}
+	}
+
+	// A cooldown holds switches back; the queue is drained again when it ends
+	if s.queueHeld() {
+		return
+	}
+
+	next, ok := s.queue.next()
+	if !ok {
+		return
+	}
+	// Claim the switch in one step, so concurrent drains can't both start one
+	// and bounce the GPU between their targets
+	if !s.switchesInFlight.CompareAndSwap(0, 1) {
+		return
+	}
+
+	log.Printf("Switching to %s for queued requests", next)
+	go func() {
+		// Don't tie the switch to any single queued request's context; it
+		// takes the priority of the most important one
+		ctx := WithPriority(context.Background(), s.queue.priority(next))
+		if err := s.runSwitch(ctx, next); err != nil {
+			log.Printf("Queued switch to %s failed: %v", next, err)
+		}
+	}()
+}
+
+// SwitchModel makes the target the active model, putting other models to sleep
+// as needed to fit the GPU memory budget
 func (s *Switcher) SwitchModel(ctx context.Context, targetModelID string) error {
+	s.switchesInFlight.Add(1)
+	return s.runSwitch(ctx, targetModelID)
+}
+
+// runSwitch performs a switch already counted in switchesInFlight, then hands
+// the outcome to requests queued for the target and drains the queue
+func (s *Switcher) runSwitch(ctx context.Context, targetModelID string) error {
+	started := time.Now()
+	from := s.awakeModels(targetModelID)
+	outcome := &switchOutcome{}
+	ctx = context.WithValue(ctx, outcomeContextKey{}, outcome)
+
+	ctx, span := tracing.Start(ctx, "Switcher.SwitchModel",
+		attribute.String("model.id", targetModelID),
+		attribute.String("enduser.id", actorFrom(ctx)))
+	err := s.switchModel(ctx, targetModelID)
+	tracing.End(span, err)
+	// A switch held back by a cooldown or higher-priority work never started, so
+	// it isn't counted as a failed switch; deferred jobs retry it many times
+	var cooldown *CooldownError
+	var preempt *PreemptionError
+	if !errors.As(err, &cooldown) && !errors.As(err, &preempt) {
+		s.metrics.SwitchFinished(targetModelID, err)
+		s.recordSwitch(targetModelID, modelIDs(from), started, err)
+		s.auditSwitch(ctx, targetModelID, modelIDs(from), started, outcome, err)
+	}
+	if cooldown != nil {
+		// Requests queued for the target wait out the cooldown instead of failing
+		s.holdQueue(cooldown.RetryAfter)
+	} else if err != nil {
+		s.queue.release(targetModelID, fmt.Errorf("%w: failed to switch to model %s: %w", ErrModelUnavailable, targetModelID, err))
+	} else {
+		s.overrideSchedules(ctx, targetModelID)
+	}
+
+	s.switchesInFlight.Add(-1)
+	s.drainQueue()
+	return err
+}
+
+// switchModel wakes the target, first putting to sleep whichever awake models
+// must make room for it in the GPU memory budget
+func (s *Switcher) switchModel(ctx context.Context, targetModelID string) error {
 	// Acquire switch lock to prevent concurrent switches
 	s.switchLock.Lock()
 	defer s.switchLock.Unlock()
 
+	ctx, done := s.beginSwitch(ctx, targetModelID)
+	defer done()
+	// A switch canceled while it waited for the lock never starts
+	if ctx.Err() != nil {
+		return fmt.Errorf("switch to %s aborted: %w", targetModelID, context.Cause(ctx))
+	}
+
 	s.mapMu.RLock()
 	targetModel, exists := s.models[targetModelID]
 	currentActive := s.activeModel
@@ -229,33 +592,81 @@ func (s *Switcher) SwitchModel(ctx context.Context, targetModelID string) error
 		return fmt.Errorf("model %s not found", targetModelID)
 	}
 
-	// Check if target model is disabled
-	if targetModel.StartupMode == models.StartupDisabled {
+	// Check if target model is disabled (without a container runtime to start it)
+	if targetModel.StartupMode == models.StartupDisabled && s.containers == nil {
 		return fmt.Errorf("model %s is disabled and cannot be activated", targetModelID)
 	}
 
-	if targetModelID == currentActive {
-		return nil // Already active
+	if targetModel.GetStatus() == models.StatusActive {
+		// Already awake; just make it the primary active model
+		s.mapMu.Lock()
+		s.activeModel = targetModelID
+		s.mapMu.Unlock()
+		return nil
+	}
+
+	// Switches that alternate too fast back off for a while
+	if err := s.checkThrashing(ctx); err != nil {
+		return err
+	}
+
+	evict, err := s.planEvictions(targetModel)
+	if err != nil {
+		return err
+	}
+
+	// Models stay awake for their min_residency before they can be evicted
+	if err := s.checkResidency(ctx, evict); err != nil {
+		return err
+	}
+
+	// Don't cut off requests that outrank the switch
+	if err := s.checkPreemption(ctx, targetModelID, evict); err != nil {
+		return err
 	}
 
-	log.Printf("Starting switch from %s to %s", currentActive, targetModelID)
+	log.Printf("Starting switch from %s to %s (evicting %v)", currentActive, targetModelID, evict)
 
-	// Step 1: Put current model to sleep
-	if currentActive != "" {
-		if err := s.sleepModel(ctx, currentActive); err != nil {
-			return fmt.Errorf("failed to sleep current model: %w", err)
+	// Requests for the models being changed wait for the switch; the rest of
+	// the awake models keep serving
+	defer s.holdModels(append([]string{targetModelID}, evict...))()
+
+	// Step 1: Put models that don't fit alongside the target to sleep (or stop them)
+	if len(evict) > 0 {
+		reportPhase(ctx, models.PhaseSleepingCurrent)
+	}
+	sleepStart := time.Now()
+	var slept []string
+	for _, id := range evict {
+		if err := s.releaseModel(ctx, id); err != nil {
+			if ctx.Err() != nil {
+				// The model may be half asleep; wake it along with the others
+				return s.abortSwitch(ctx, targetModelID, false, append(slept, id))
+			}
+			s.reactivateModels(ctx, slept)
+			return fmt.Errorf("failed to sleep model %s: %w", id, err)
 		}
+		slept = append(slept, id)
+	}
+	if ctx.Err() != nil {
+		return s.abortSwitch(ctx, targetModelID, false, slept)
+	}
+	if len(evict) > 0 {
+		s.metrics.ObserveSwitchPhase("sleep", time.Since(sleepStart).Seconds())
 	}
 
-	// Step 2: Wake up target model
-	if err := s.activateModel(ctx, targetModelID); err != nil {
-		// Try to reactivate previous model
-		if currentActive != "" {
-			log.Printf("Failed to activate %s, attempting to reactivate %s", targetModelID, currentActive)
-			s.activateModel(ctx, currentActive)
+	// Step 2: Wake up target model (or start its container)
+	reportPhase(ctx, models.PhaseWakingTarget)
+	wakeStart := time.Now()
+	if err := s.bringUpModel(ctx, targetModelID); err != nil {
+		if ctx.Err() != nil {
+			return s.abortSwitch(ctx, targetModelID, true, slept)
 		}
+		log.Printf("Failed to activate %s, attempting to reactivate %v", targetModelID, slept)
+		s.reactivateModels(ctx, slept)
 		return fmt.Errorf("failed to activate target model: %w", err)
 	}
+	s.metrics.ObserveSwitchPhase("wake", time.Since(wakeStart).Seconds())
 
 	// Update active model
 	s.mapMu.Lock()
@@ -263,16 +674,35 @@ func (s *Switcher) SwitchModel(ctx context.Context, targetModelID string) error
 	s.mapMu.Unlock()
 
 	log.Printf("Successfully switched to %s", targetModelID)
+	s.recordSwitchDone(targetModelID)
 	return nil
 }
 
+// reactivateModels wakes models that were put to sleep by a switch that failed
+func (s *Switcher) reactivateModels(ctx context.Context, modelIDs []string) {
+	if len(modelIDs) == 0 {
+		return
+	}
+	s.metrics.SwitchRolledBack()
+	reportRollback(ctx)
+
+	for _, id := range modelIDs {
+		if err := s.bringUpModel(ctx, id); err != nil {
+			log.Printf("Failed to reactivate %s: %v", id, err)
+		}
+	}
+}
+
 // sleepModel puts a model into sleep mode
-func (s *Switcher) sleepModel(ctx context.Context, modelID string) error {
+func (s *Switcher) sleepModel(ctx context.Context, modelID string) (err error) {
+	ctx, span := tracing.Start(ctx, "Switcher.sleepModel", attribute.String("model.id", modelID))
+	defer func() { tracing.End(span, err) }()
+
 	s.mapMu.RLock()
 	model := s.models[modelID]
 	s.mapMu.RUnlock()
 
-	model.MarkSwitching()
+	s.mark(ctx, model, models.StatusSwitching)
 
 	log.Printf("Putting model %s to sleep", modelID)
 
@@ -294,51 +724,79 @@ func (s *Switcher) sleepModel(ctx context.Context, modelID string) error {
 		MaxDelay:     3200 * time.Millisecond,
 		Multiplier:   2.0,
 	}
-	err := utils.PollUntil(ctx, cfg, func() (bool, error) {
-		return s.vllmClient.IsSleeping(ctx, model.ContainerName, model.Port)
+	pollCtx, pollSpan := tracing.Start(ctx, "Switcher.confirmSleep", attribute.String("model.id", modelID))
+	pollErr := utils.PollUntil(pollCtx, cfg, func() (bool, error) {
+		return s.vllmClient.IsSleeping(pollCtx, model.ContainerName, model.Port)
 	})
+	tracing.End(pollSpan, pollErr)
 
-	if err != nil {
-		log.Printf("Warning: Could not confirm sleep state for %s: %v (assuming success)", modelID, err)
+	if pollErr != nil {
+		log.Printf("Warning: Could not confirm sleep state for %s: %v (assuming success)", modelID, pollErr)
 	}
 
-	model.MarkSleeping()
+	s.mark(ctx, model, models.StatusSleeping)
 	log.Printf("Model %s is now sleeping", modelID)
 	return nil
 }
 
 // activateModel wakes up a model from sleep mode
-func (s *Switcher) activateModel(ctx context.Context, modelID string) error {
+func (s *Switcher) activateModel(ctx context.Context, modelID string) (err error) {
+	ctx, span := tracing.Start(ctx, "Switcher.activateModel", attribute.String("model.id", modelID))
+	defer func() { tracing.End(span, err) }()
+
 	s.mapMu.RLock()
 	model := s.models[modelID]
 	s.mapMu.RUnlock()
 
-	model.MarkSwitching()
+	s.mark(ctx, model, models.StatusSwitching)
 
 	log.Printf("Waking up model %s", modelID)
 
 	// Call vLLM wake_up endpoint
 	if err := s.vllmClient.WakeUp(ctx, model.ContainerName, model.Port); err != nil {
-		model.MarkError()
+		// A canceled switch puts the model back to sleep rather than leaving it in error
+		if ctx.Err() == nil {
+			model.MarkError()
+		}
 		return fmt.Errorf("failed to wake up model: %w", err)
 	}
 
-	// Wait for model to be healthy
+	return s.waitHealthy(ctx, model)
+}
+
+// waitHealthy polls a model's health endpoint until it is ready to serve
+func (s *Switcher) waitHealthy(ctx context.Context, model *models.Model) (err error) {
+	modelID := model.ID
+	ctx, span := tracing.Start(ctx, "Switcher.waitHealthy", attribute.String("model.id", modelID))
+	defer func() { tracing.End(span, err) }()
 	maxRetries := s.maxRetries
 	interval := s.healthCheckInterval
 
 	for i := 0; i < maxRetries; i++ {
 		log.Printf("Health check %d/%d for model %s", i+1, maxRetries, modelID)
+		reportAttempt(ctx, i+1, maxRetries)
 
 		healthy, err := s.vllmClient.Health(ctx, model.ContainerName, model.Port)
 		if err == nil && healthy {
-			model.MarkActive()
+			s.metrics.ObserveHealthCheckAttempts(i + 1)
+			s.mark(ctx, model, models.StatusActive)
 			log.Printf("Model %s is now active and healthy", modelID)
 			return nil
 		}
 
+		// A server the breaker has given up on won't come back by itself; only a
+		// container that is still starting is worth waiting for
+		if errors.Is(err, vllm.ErrCircuitOpen) && !s.containerRunning(ctx, model) {
+			model.MarkError()
+			return fmt.Errorf("model %s is unreachable: %w", modelID, err)
+		}
+
 		if i < maxRetries-1 {
-			time.Sleep(interval)
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return fmt.Errorf("stopped waiting for model %s to become healthy: %w", modelID, context.Cause(ctx))
+			}
 		}
 	}
 
@@ -352,8 +810,10 @@ func (s *Switcher) determineSleepLevel(model *models.Model) int {
 
 	// If available RAM is greater than model's GPU memory requirement, use level 1
 	// Otherwise, use level 2 to save RAM
+	level := 2 // Level 2: discard weights
 	if availableRAMGB >= model.GPUMemoryGB {
-		return 1 // Level 1: offload to CPU RAM
+		level = 1 // Level 1: offload to CPU RAM
 	}
-	return 2 // Level 2: discard weights
+	s.metrics.SetSleepLevel(model.ID, level)
+	return level
 }
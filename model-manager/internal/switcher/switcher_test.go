@@ -3,12 +3,18 @@ package switcher
 import (
 	"context"
 	"errors"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/zheng/homeGPT/internal/metrics"
 	"github.com/zheng/homeGPT/internal/system"
 	"github.com/zheng/homeGPT/internal/vllm"
 	"github.com/zheng/homeGPT/pkg/models"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestNew(t *testing.T) {
@@ -581,3 +587,241 @@ func TestResyncModels_SkipsDisabled(t *testing.T) {
 		t.Errorf("expected model-b to remain disabled, got %s", s.models["model-b"].GetStatus())
 	}
 }
+
+func TestEnsureActive_SwitchesSleepingModel(t *testing.T) {
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupSleep},
+		},
+	}
+
+	mockClient := vllm.NewMockClient()
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		if host == "vllm-b" {
+			return true, nil
+		}
+		for _, call := range mockClient.SleepCalls {
+			if call.Host == host && call.Port == port {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	s := NewWithClient(cfg, mockClient, WithMaxRetries(2), WithHealthCheckInterval(10*time.Millisecond))
+	s.WaitForInit()
+
+	model, err := s.EnsureActive(context.Background(), "model-b")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if model.ID != "model-b" || model.GetStatus() != models.StatusActive {
+		t.Errorf("expected active model-b snapshot, got %s (%s)", model.ID, model.GetStatus())
+	}
+
+	if s.activeModel != "model-b" {
+		t.Errorf("expected active model 'model-b', got '%s'", s.activeModel)
+	}
+
+	if len(mockClient.WakeUpCalls) != 1 {
+		t.Errorf("expected 1 wake_up call, got %d", len(mockClient.WakeUpCalls))
+	}
+}
+
+func TestEnsureActive_AlreadyActive(t *testing.T) {
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive},
+		},
+	}
+
+	mockClient := vllm.NewMockClient()
+	s := NewWithClient(cfg, mockClient, WithMaxRetries(2), WithHealthCheckInterval(10*time.Millisecond))
+	s.WaitForInit()
+
+	if _, err := s.EnsureActive(context.Background(), "model-a"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(mockClient.SleepCalls) != 0 || len(mockClient.WakeUpCalls) != 0 {
+		t.Error("expected no sleep or wake_up calls for an already active model")
+	}
+}
+
+func TestEnsureActive_Errors(t *testing.T) {
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupDisabled},
+		},
+	}
+
+	mockClient := vllm.NewMockClient()
+	s := NewWithClient(cfg, mockClient, WithMaxRetries(2), WithHealthCheckInterval(10*time.Millisecond))
+	s.WaitForInit()
+
+	ctx := context.Background()
+
+	if _, err := s.EnsureActive(ctx, "nonexistent"); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("expected ErrModelNotFound, got %v", err)
+	}
+
+	if _, err := s.EnsureActive(ctx, "model-b"); !errors.Is(err, ErrModelUnavailable) {
+		t.Errorf("expected ErrModelUnavailable for disabled model, got %v", err)
+	}
+
+	if len(mockClient.WakeUpCalls) != 0 {
+		t.Errorf("expected no wake_up calls, got %d", len(mockClient.WakeUpCalls))
+	}
+}
+
+func TestSwitchModel_RecordsMetrics(t *testing.T) {
+	m := metrics.New()
+
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive, GPUMemoryGB: 16},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupSleep, GPUMemoryGB: 24},
+		},
+	}
+
+	mockClient := vllm.NewMockClient()
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		if host == "vllm-b" {
+			return true, nil
+		}
+		return len(mockClient.SleepCalls) > 0, nil
+	}
+
+	s := NewWithClient(cfg, mockClient,
+		WithMetrics(m),
+		WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 8}),
+		WithMaxRetries(2),
+		WithHealthCheckInterval(10*time.Millisecond))
+	s.WaitForInit()
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	expected := []string{
+		`homegpt_switches_total{model="model-b",result="success"} 1`,
+		`homegpt_switch_phase_duration_seconds_count{phase="sleep"} 1`,
+		`homegpt_switch_phase_duration_seconds_count{phase="wake"} 1`,
+		`homegpt_health_check_attempts_count 1`,
+		`homegpt_sleep_level{model="model-a"} 2`,
+		`homegpt_model_status{model="model-b",status="active"} 1`,
+		`homegpt_model_status{model="model-a",status="sleeping"} 1`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q", want)
+		}
+	}
+}
+
+func TestSwitchModel_RecordsSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	cfg := &models.Config{
+		Models: []models.Model{
+			{ID: "model-a", ContainerName: "vllm-a", Port: 8000, StartupMode: models.StartupActive, GPUMemoryGB: 16},
+			{ID: "model-b", ContainerName: "vllm-b", Port: 8000, StartupMode: models.StartupSleep, GPUMemoryGB: 24},
+		},
+	}
+
+	mockClient := vllm.NewMockClient()
+	mockClient.IsSleepingFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		return true, nil
+	}
+
+	s := NewWithClient(cfg, mockClient,
+		WithoutBackgroundTasks(),
+		WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 128}),
+		WithMaxRetries(2),
+		WithHealthCheckInterval(10*time.Millisecond))
+
+	if err := s.SwitchModel(context.Background(), "model-b"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	root, ok := spans["Switcher.SwitchModel"]
+	if !ok {
+		t.Fatal("expected a Switcher.SwitchModel span")
+	}
+	for _, name := range []string{"Switcher.sleepModel", "Switcher.activateModel"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("expected a %s span", name)
+			continue
+		}
+		if span.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("expected %s to be a child of Switcher.SwitchModel", name)
+		}
+	}
+	for _, name := range []string{"Switcher.confirmSleep", "Switcher.waitHealthy"} {
+		if _, ok := spans[name]; !ok {
+			t.Errorf("expected a %s span", name)
+		}
+	}
+}
+
+func TestSwitchModel_CircuitOpenFailsFast(t *testing.T) {
+	cfg := stateTestConfig()
+	cfg.CircuitBreaker = models.BreakerConfig{FailureThreshold: 2, OpenSeconds: 60}
+
+	// model-b's container crashed: it can't be reached at all
+	mockClient := newStatefulMock("vllm-b")
+	mockClient.HealthFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		if host == "vllm-b" {
+			return false, errors.New("connection refused")
+		}
+		return true, nil
+	}
+
+	s := NewWithClient(cfg, mockClient,
+		WithoutBackgroundTasks(),
+		WithCircuitBreaker(),
+		WithRAMFetcher(&system.MockRAMFetcher{AvailableRAMGB: 128}),
+		WithMaxRetries(450),
+		WithHealthCheckInterval(10*time.Millisecond))
+
+	err := s.SwitchModel(context.Background(), "model-b")
+	if !errors.Is(err, vllm.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	calls := 0
+	for _, call := range mockClient.HealthCalls {
+		if call.Host == "vllm-b" {
+			calls++
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected health checks to stop once the circuit opened, got %d calls", calls)
+	}
+
+	resp := s.GetModels()
+	if state := resp.Circuits["model-b"].State; state != models.CircuitOpen {
+		t.Errorf("expected model-b's circuit to be open, got %s", state)
+	}
+	if state := resp.Circuits["model-a"].State; state != models.CircuitClosed {
+		t.Errorf("expected model-a's circuit to be closed, got %s", state)
+	}
+	if resp.ActiveModel != "model-a" {
+		t.Errorf("expected the switch to roll back to model-a, got %s", resp.ActiveModel)
+	}
+}
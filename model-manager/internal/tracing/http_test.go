@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware_ContinuesCallerTrace(t *testing.T) {
+	recorder := useRecorder(t)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+
+	var handlerSpan trace.SpanContext
+	r.GET("/models", func(c *gin.Context) {
+		handlerSpan = trace.SpanContextFromContext(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/models", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+
+	if span.Name() != "GET /models" {
+		t.Errorf("expected span name 'GET /models', got %q", span.Name())
+	}
+	if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected caller's trace ID, got %s", span.SpanContext().TraceID())
+	}
+	if span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("expected caller's span as parent, got %s", span.Parent().SpanID())
+	}
+	if handlerSpan.SpanID() != span.SpanContext().SpanID() {
+		t.Error("expected the server span in the handler's request context")
+	}
+}
+
+func TestTransport_PropagatesTraceContext(t *testing.T) {
+	recorder := useRecorder(t)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, parent := Start(t.Context(), "parent")
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/health", nil)
+
+	client := &http.Client{Transport: NewTransport(nil)}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	if req.Header.Get("traceparent") != "" {
+		t.Error("expected the caller's request to be left unmodified")
+	}
+
+	var clientSpan trace.SpanContext
+	for _, span := range recorder.Ended() {
+		if span.Name() == "GET /health" {
+			clientSpan = span.SpanContext()
+			if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+				t.Error("expected the client span to be a child of the caller's span")
+			}
+			if span.Status().Code.String() != "Error" {
+				t.Errorf("expected error status for a 503, got %v", span.Status().Code)
+			}
+		}
+	}
+	if !clientSpan.IsValid() {
+		t.Fatal("expected a client span for the request")
+	}
+
+	want := "00-" + clientSpan.TraceID().String() + "-" + clientSpan.SpanID().String() + "-01"
+	if traceparent != want {
+		t.Errorf("expected traceparent %q, got %q", want, traceparent)
+	}
+}
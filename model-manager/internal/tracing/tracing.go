@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by the model manager
+const instrumentationName = "github.com/zheng/homeGPT"
+
+// serviceName is reported as service.name unless OTEL_SERVICE_NAME overrides it
+const serviceName = "model-manager"
+
+// Tracer returns the tracer used for model manager spans. Until Setup installs a
+// provider it is a no-op, so instrumented code works without tracing configured.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span as a child of any span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on the span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Setup installs a global tracer provider chosen by OTEL_TRACES_EXPORTER:
+//   - "otlp": OTLP over HTTP, configured by the standard OTEL_EXPORTER_OTLP_* variables
+//   - "file": JSON lines written to OTEL_TRACES_FILE (default traces.jsonl)
+//   - unset or "none": tracing disabled
+//
+// The returned function flushes and stops the exporter.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	// Propagate W3C trace context so callers' traces continue through the manager
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, closer, err := newExporter(ctx, os.Getenv("OTEL_TRACES_EXPORTER"))
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// Detectors applied later win, so OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES
+	// override the default service name
+	resource, err := sdkresource.New(ctx,
+		sdkresource.WithAttributes(semconv.ServiceName(serviceName)),
+		sdkresource.WithTelemetrySDK(),
+		sdkresource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}, nil
+}
+
+// newExporter creates the span exporter for the given OTEL_TRACES_EXPORTER value
+func newExporter(ctx context.Context, kind string) (sdktrace.SpanExporter, io.Closer, error) {
+	switch kind {
+	case "", "none":
+		return nil, nil, nil
+	case "otlp":
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		log.Printf("Exporting traces over OTLP/HTTP")
+		return exporter, nil, nil
+	case "file":
+		path := os.Getenv("OTEL_TRACES_FILE")
+		if path == "" {
+			path = "traces.jsonl"
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("failed to create file exporter: %w", err)
+		}
+		log.Printf("Writing traces to %s", path)
+		return exporter, f, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q (expected otlp, file or none)", kind)
+	}
+}
@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// useRecorder installs a tracer provider that records ended spans for the test,
+// along with the W3C trace context propagator
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder
+}
+
+func TestEnd_RecordsError(t *testing.T) {
+	recorder := useRecorder(t)
+
+	_, span := Start(context.Background(), "failing")
+	End(span, errors.New("boom"))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("expected error status, got %v", spans[0].Status().Code)
+	}
+	if len(spans[0].Events()) != 1 {
+		t.Errorf("expected the error to be recorded as an event, got %d events", len(spans[0].Events()))
+	}
+}
+
+func TestSetup_Disabled(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "")
+
+	shutdown, err := Setup(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("expected no error on shutdown, got %v", err)
+	}
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "zipkin")
+
+	if _, err := Setup(context.Background()); err == nil {
+		t.Fatal("expected error for unknown exporter")
+	}
+}
+
+func TestSetup_File(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	t.Setenv("OTEL_TRACES_EXPORTER", "file")
+	t.Setenv("OTEL_TRACES_FILE", path)
+
+	shutdown, err := Setup(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	_, span := Start(context.Background(), "written")
+	End(span, nil)
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("expected no error on shutdown, got %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected trace file, got %v", err)
+	}
+	if len(data) == 0 {
+		t.Error("expected the span to be written to the trace file")
+	}
+}
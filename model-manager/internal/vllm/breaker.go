@@ -0,0 +1,233 @@
+package vllm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+)
+
+// ErrCircuitOpen is matched by errors returned without calling a vLLM server
+// because its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitOpenError is returned instead of calling a vLLM server that has failed
+// too many times in a row
+type CircuitOpenError struct {
+	Host       string
+	Port       int
+	RetryAfter time.Duration // Time until the next probe call is let through
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker open for %s:%d, retry in %s", e.Host, e.Port, e.RetryAfter.Round(time.Second))
+}
+
+// Is makes errors.Is(err, ErrCircuitOpen) match
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitReporter is implemented by clients that keep a circuit breaker per server
+type CircuitReporter interface {
+	Circuit(host string, port int) models.CircuitStatus
+}
+
+// circuit is the breaker state of a single host:port
+type circuit struct {
+	failures int
+	openedAt time.Time // Zero while closed
+	probing  bool      // A half-open probe call is in flight
+	lastErr  error
+}
+
+// Breaker wraps a VLLMClient with a circuit breaker per host:port. After
+// threshold consecutive failed calls the circuit opens and calls fail fast with
+// a *CircuitOpenError. Once openTimeout has passed the circuit is half-open: the
+// next call goes through as a probe, closing the circuit if it succeeds and
+// opening it again if it fails. The periodic resync keeps probing servers that
+// nothing else calls.
+type Breaker struct {
+	client      VLLMClient
+	threshold   int
+	openTimeout time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// Ensure Breaker implements VLLMClient and CircuitReporter
+var (
+	_ VLLMClient      = (*Breaker)(nil)
+	_ CircuitReporter = (*Breaker)(nil)
+)
+
+// NewBreaker wraps client with a circuit breaker. A zero threshold or openTimeout
+// uses the defaults (5 failures, 30 seconds).
+func NewBreaker(client VLLMClient, threshold int, openTimeout time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	if openTimeout <= 0 {
+		openTimeout = defaultOpenTimeout
+	}
+	return &Breaker{
+		client:      client,
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		now:         time.Now,
+		circuits:    make(map[string]*circuit),
+	}
+}
+
+// Health checks if the vLLM server is healthy. A server that answers unhealthy
+// is reachable, so only errors count as failures.
+func (b *Breaker) Health(ctx context.Context, host string, port int) (bool, error) {
+	if err := b.allow(host, port); err != nil {
+		return false, err
+	}
+	healthy, err := b.client.Health(ctx, host, port)
+	b.record(ctx, host, port, err)
+	return healthy, err
+}
+
+// IsSleeping checks if the vLLM server is in sleep mode
+func (b *Breaker) IsSleeping(ctx context.Context, host string, port int) (bool, error) {
+	if err := b.allow(host, port); err != nil {
+		return false, err
+	}
+	sleeping, err := b.client.IsSleeping(ctx, host, port)
+	b.record(ctx, host, port, err)
+	return sleeping, err
+}
+
+// Sleep puts the vLLM server to sleep
+func (b *Breaker) Sleep(ctx context.Context, host string, port int, level int) error {
+	if err := b.allow(host, port); err != nil {
+		return err
+	}
+	err := b.client.Sleep(ctx, host, port, level)
+	b.record(ctx, host, port, err)
+	return err
+}
+
+// WakeUp wakes up the vLLM server from sleep
+func (b *Breaker) WakeUp(ctx context.Context, host string, port int) error {
+	if err := b.allow(host, port); err != nil {
+		return err
+	}
+	err := b.client.WakeUp(ctx, host, port)
+	b.record(ctx, host, port, err)
+	return err
+}
+
+// Circuit reports the breaker state of a server
+func (b *Breaker) Circuit(host string, port int) models.CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[circuitKey(host, port)]
+	if !ok {
+		return models.CircuitStatus{State: models.CircuitClosed}
+	}
+
+	status := models.CircuitStatus{
+		State:               b.state(c),
+		ConsecutiveFailures: c.failures,
+	}
+	if !c.openedAt.IsZero() {
+		openedAt := c.openedAt
+		status.OpenedAt = &openedAt
+	}
+	if c.lastErr != nil {
+		status.LastError = c.lastErr.Error()
+	}
+	return status
+}
+
+// allow returns a *CircuitOpenError if a call to the server must fail fast, and
+// otherwise lets it through, marking it as the probe if the circuit is half-open
+func (b *Breaker) allow(host string, port int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[circuitKey(host, port)]
+	if !ok {
+		return nil
+	}
+
+	switch b.state(c) {
+	case models.CircuitOpen:
+		return &CircuitOpenError{Host: host, Port: port, RetryAfter: c.openedAt.Add(b.openTimeout).Sub(b.now())}
+	case models.CircuitHalfOpen:
+		if c.probing {
+			return &CircuitOpenError{Host: host, Port: port, RetryAfter: b.openTimeout}
+		}
+		c.probing = true
+	}
+	return nil
+}
+
+// record updates the server's circuit with the outcome of a call. Calls cut
+// short by their own context say nothing about the server and are ignored.
+func (b *Breaker) record(ctx context.Context, host string, port int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := circuitKey(host, port)
+	c, ok := b.circuits[key]
+	if err != nil && ctx.Err() != nil {
+		if ok {
+			c.probing = false
+		}
+		return
+	}
+
+	if err == nil {
+		if ok && !c.openedAt.IsZero() {
+			log.Printf("Circuit breaker for %s closed", key)
+		}
+		delete(b.circuits, key)
+		return
+	}
+
+	if !ok {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+	c.failures++
+	c.lastErr = err
+
+	reopen := c.probing
+	c.probing = false
+	if reopen || (c.openedAt.IsZero() && c.failures >= b.threshold) {
+		c.openedAt = b.now()
+		log.Printf("Circuit breaker for %s opened after %d consecutive failures: %v", key, c.failures, err)
+	}
+}
+
+// state derives a circuit's state from when it opened
+func (b *Breaker) state(c *circuit) models.CircuitState {
+	switch {
+	case c.openedAt.IsZero():
+		return models.CircuitClosed
+	case b.now().Sub(c.openedAt) < b.openTimeout:
+		return models.CircuitOpen
+	default:
+		return models.CircuitHalfOpen
+	}
+}
+
+func circuitKey(host string, port int) string {
+	return fmt.Sprintf("%s:%d", host, port)
+}
@@ -0,0 +1,169 @@
+package vllm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zheng/homeGPT/pkg/models"
+)
+
+// newTestBreaker returns a breaker around a mock whose health checks fail while
+// *down is true, with a clock the test moves by hand
+func newTestBreaker(down *bool) (*Breaker, *MockClient, *time.Time) {
+	mock := NewMockClient()
+	mock.HealthFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		if *down {
+			return false, errors.New("connection refused")
+		}
+		return true, nil
+	}
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBreaker(mock, 3, 30*time.Second)
+	b.now = func() time.Time { return now }
+	return b, mock, &now
+}
+
+func TestBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	down := true
+	b, mock, _ := newTestBreaker(&down)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := b.Health(ctx, "vllm-a", 8000); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d: expected the server's error, got %v", i+1, err)
+		}
+	}
+
+	_, err := b.Health(ctx, "vllm-a", 8000)
+	var circuitErr *CircuitOpenError
+	if !errors.As(err, &circuitErr) {
+		t.Fatalf("expected *CircuitOpenError, got %v", err)
+	}
+	if circuitErr.RetryAfter != 30*time.Second {
+		t.Errorf("expected retry after 30s, got %s", circuitErr.RetryAfter)
+	}
+	if len(mock.HealthCalls) != 3 {
+		t.Errorf("expected the open circuit to skip the server, got %d calls", len(mock.HealthCalls))
+	}
+
+	status := b.Circuit("vllm-a", 8000)
+	if status.State != models.CircuitOpen || status.ConsecutiveFailures != 3 || status.OpenedAt == nil || status.LastError == "" {
+		t.Errorf("expected open circuit with 3 failures, got %+v", status)
+	}
+
+	// Other servers are unaffected
+	if err := b.WakeUp(ctx, "vllm-b", 8000); err != nil {
+		t.Errorf("expected vllm-b to be called, got %v", err)
+	}
+}
+
+func TestBreaker_SuccessResetsFailures(t *testing.T) {
+	down := true
+	b, _, _ := newTestBreaker(&down)
+	ctx := context.Background()
+
+	b.Health(ctx, "vllm-a", 8000)
+	b.Health(ctx, "vllm-a", 8000)
+	down = false
+	b.Health(ctx, "vllm-a", 8000)
+	down = true
+	b.Health(ctx, "vllm-a", 8000)
+	b.Health(ctx, "vllm-a", 8000)
+
+	if status := b.Circuit("vllm-a", 8000); status.State != models.CircuitClosed || status.ConsecutiveFailures != 2 {
+		t.Errorf("expected closed circuit with 2 failures, got %+v", status)
+	}
+}
+
+func TestBreaker_HalfOpenProbe(t *testing.T) {
+	down := true
+	b, mock, now := newTestBreaker(&down)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		b.Health(ctx, "vllm-a", 8000)
+	}
+
+	*now = now.Add(30 * time.Second)
+	if state := b.Circuit("vllm-a", 8000).State; state != models.CircuitHalfOpen {
+		t.Fatalf("expected half-open circuit after the open timeout, got %s", state)
+	}
+
+	// A failed probe opens the circuit for another timeout
+	if _, err := b.Health(ctx, "vllm-a", 8000); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("expected the probe to reach the server")
+	}
+	if _, err := b.Health(ctx, "vllm-a", 8000); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the circuit to open again after a failed probe, got %v", err)
+	}
+	if len(mock.HealthCalls) != 4 {
+		t.Errorf("expected 4 calls to reach the server, got %d", len(mock.HealthCalls))
+	}
+
+	// A successful probe closes it
+	*now = now.Add(30 * time.Second)
+	down = false
+	if healthy, err := b.Health(ctx, "vllm-a", 8000); err != nil || !healthy {
+		t.Fatalf("expected the probe to succeed, got %v, %v", healthy, err)
+	}
+	if status := b.Circuit("vllm-a", 8000); status.State != models.CircuitClosed || status.ConsecutiveFailures != 0 {
+		t.Errorf("expected closed circuit after a successful probe, got %+v", status)
+	}
+}
+
+func TestBreaker_HalfOpenLetsOneProbeThrough(t *testing.T) {
+	down := true
+	b, mock, now := newTestBreaker(&down)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		b.Health(ctx, "vllm-a", 8000)
+	}
+	*now = now.Add(30 * time.Second)
+
+	// Hold the probe in flight while another call arrives
+	probing := make(chan struct{})
+	release := make(chan struct{})
+	mock.HealthFunc = func(ctx context.Context, host string, port int) (bool, error) {
+		close(probing)
+		<-release
+		return true, nil
+	}
+	done := make(chan error)
+	go func() {
+		_, err := b.Health(ctx, "vllm-a", 8000)
+		done <- err
+	}()
+	<-probing
+
+	if _, err := b.Health(ctx, "vllm-a", 8000); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected a second call during the probe to fail fast, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("expected the probe to succeed, got %v", err)
+	}
+	if state := b.Circuit("vllm-a", 8000).State; state != models.CircuitClosed {
+		t.Errorf("expected closed circuit, got %s", state)
+	}
+}
+
+func TestBreaker_IgnoresCanceledCalls(t *testing.T) {
+	mock := NewMockClient()
+	mock.SleepFunc = func(ctx context.Context, host string, port int, level int) error {
+		return ctx.Err()
+	}
+	b := NewBreaker(mock, 1, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.Sleep(ctx, "vllm-a", 8000, 1)
+
+	if status := b.Circuit("vllm-a", 8000); status.State != models.CircuitClosed || status.ConsecutiveFailures != 0 {
+		t.Errorf("expected a canceled call not to count, got %+v", status)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/zheng/homeGPT/internal/tracing"
 )
 
 // Client wraps HTTP calls to vLLM servers
@@ -18,7 +20,8 @@ type Client struct {
 func NewClient() *Client {
 	return &Client{
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout:   10 * time.Second,
+			Transport: tracing.NewTransport(nil), // Span per vLLM call
 		},
 	}
 }
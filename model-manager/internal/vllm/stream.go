@@ -0,0 +1,119 @@
+package vllm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/zheng/homeGPT/internal/tracing"
+)
+
+// DefaultStreamIdleTimeout is how long a relayed response may go without producing data
+const DefaultStreamIdleTimeout = 5 * time.Minute
+
+// ErrStreamIdle is returned when a relayed response body produces no data for the idle timeout
+var ErrStreamIdle = errors.New("vllm stream idle timeout")
+
+// streamingContextKey marks requests that ask for a streamed response
+type streamingContextKey struct{}
+
+// WithStreaming marks requests made under ctx as asking for a streamed response,
+// whose headers vLLM sends as soon as generation starts
+func WithStreaming(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamingContextKey{}, true)
+}
+
+// streaming reports whether ctx carries a request for a streamed response
+func streaming(ctx context.Context) bool {
+	on, _ := ctx.Value(streamingContextKey{}).(bool)
+	return on
+}
+
+// StreamTransport is an http.RoundTripper for relaying inference traffic to vLLM.
+// Unlike Client it has no overall timeout, since a generation can run for many
+// minutes, but a response body that stops producing data within the idle
+// timeout is aborted. So is a streamed response whose headers don't arrive in
+// time; a non-streamed one only gets headers once the whole generation is done.
+// Requests are cancelled through their context, so a client disconnect cancels
+// upstream.
+type StreamTransport struct {
+	base        http.RoundTripper
+	streaming   http.RoundTripper // Like base, but times out waiting for response headers
+	idleTimeout time.Duration
+}
+
+// NewStreamTransport creates a streaming transport with the given idle-read timeout
+func NewStreamTransport(idleTimeout time.Duration) *StreamTransport {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	// Relay bodies byte for byte instead of transparently decompressing them
+	base.DisableCompression = true
+
+	// The body's idle timer only starts once headers arrive
+	streamingBase := base.Clone()
+	streamingBase.ResponseHeaderTimeout = idleTimeout
+
+	return &StreamTransport{
+		base:        tracing.NewTransport(base),
+		streaming:   tracing.NewTransport(streamingBase),
+		idleTimeout: idleTimeout,
+	}
+}
+
+// RoundTrip sends the request and wraps the response body with an idle-read timeout
+func (t *StreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if streaming(req.Context()) {
+		base = t.streaming
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = newIdleTimeoutBody(resp.Body, t.idleTimeout)
+	return resp, nil
+}
+
+// idleTimeoutBody closes the underlying body when no data arrives for the timeout,
+// which unblocks any pending Read
+type idleTimeoutBody struct {
+	body     io.ReadCloser
+	timeout  time.Duration
+	timer    *time.Timer
+	timedOut atomic.Bool
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, timeout time.Duration) *idleTimeoutBody {
+	b := &idleTimeoutBody{
+		body:    body,
+		timeout: timeout,
+	}
+	b.timer = time.AfterFunc(timeout, func() {
+		b.timedOut.Store(true)
+		body.Close()
+	})
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if b.timedOut.Load() {
+		return n, ErrStreamIdle
+	}
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	return b.body.Close()
+}
+
+// Ensure StreamTransport implements http.RoundTripper
+var _ http.RoundTripper = (*StreamTransport)(nil)
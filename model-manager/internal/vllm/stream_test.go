@@ -0,0 +1,130 @@
+package vllm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStreamTransport_RelaysChunks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			w.Write([]byte("data: chunk\n\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	// Idle timeout is longer than the gap between chunks but shorter than the whole stream
+	client := &http.Client{Transport: NewStreamTransport(40 * time.Millisecond)}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("expected full stream without idle timeout, got %v", err)
+	}
+
+	if string(body) != "data: chunk\n\ndata: chunk\n\ndata: chunk\n\n" {
+		t.Errorf("unexpected body: %q", string(body))
+	}
+}
+
+func TestStreamTransport_IdleTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+		// Stall until the test finishes
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client := &http.Client{Transport: NewStreamTransport(30 * time.Millisecond)}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "data: first\n" {
+		t.Fatalf("expected first event, got %q (%v)", line, err)
+	}
+
+	start := time.Now()
+	_, err = io.ReadAll(reader)
+	if !errors.Is(err, ErrStreamIdle) {
+		t.Fatalf("expected ErrStreamIdle, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected idle timeout to fire quickly, took %v", elapsed)
+	}
+}
+
+func TestStreamTransport_StreamingHeaderTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Accept the request but never send headers
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client := &http.Client{Transport: NewStreamTransport(30 * time.Millisecond)}
+
+	req, _ := http.NewRequestWithContext(WithStreaming(context.Background()), "GET", server.URL, nil)
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected the streamed request to time out waiting for headers")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the header timeout to fire quickly, took %v", elapsed)
+	}
+}
+
+func TestStreamTransport_NonStreamingWaitsForHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A non-streamed completion only responds once generation is done
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`{"choices":[]}`))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewStreamTransport(30 * time.Millisecond)}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected a non-streamed request to wait for headers, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != `{"choices":[]}` {
+		t.Errorf("expected the full response, got %q (%v)", string(body), err)
+	}
+}
@@ -2,8 +2,12 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
 	"sync"
 	"time"
+
+	"github.com/robfig/cron/v3"
 )
 
 // ModelStatus represents the current state of a model
@@ -28,20 +32,107 @@ const (
 
 // Model represents a vLLM model configuration and state
 type Model struct {
-	mu sync.Mutex // Protects mutable fields (status, lastActive)
+	mu sync.Mutex // Protects mutable fields (status, lastActive, activatedAt, inflight, priorities, observer)
 
 	// Immutable config fields (set once, read-only after init)
-	ID            string      `json:"id" yaml:"id"`
-	Name          string      `json:"name" yaml:"name"`
-	ContainerName string      `json:"container_name" yaml:"container_name"`
-	Port          int         `json:"port" yaml:"port"`
-	HostPort      int         `json:"host_port" yaml:"host_port"`
-	GPUMemoryGB   float64     `json:"gpu_memory_gb" yaml:"gpu_memory_gb"`
-	StartupMode   StartupMode `json:"startup_mode" yaml:"startup_mode"`
+	ID            string           `json:"id" yaml:"id"`
+	Name          string           `json:"name" yaml:"name"`
+	ContainerName string           `json:"container_name" yaml:"container_name"`
+	Port          int              `json:"port" yaml:"port"`
+	HostPort      int              `json:"host_port" yaml:"host_port"`
+	GPUMemoryGB   float64          `json:"gpu_memory_gb" yaml:"gpu_memory_gb"`
+	GPUs          []int            `json:"gpus" yaml:"gpus"` // CUDA devices the model is sharded across (empty = all devices)
+	StartupMode   StartupMode      `json:"startup_mode" yaml:"startup_mode"`
+	IdleTimeout   time.Duration    `json:"idle_timeout" yaml:"idle_timeout"`   // Sleep after this long without traffic (0 = never)
+	MinResidency  time.Duration    `json:"min_residency" yaml:"min_residency"` // Stay awake at least this long once activated, unless forced (0 = no minimum)
+	Container     *ContainerConfig `json:"-" yaml:"container"`                 // How to create the container if it doesn't exist (optional)
 
 	// Mutable state fields (protected by mu)
-	status     ModelStatus
-	lastActive *time.Time
+	status      ModelStatus
+	lastActive  *time.Time
+	activatedAt *time.Time        // When the model last became active
+	inflight    int               // Inference requests currently being served
+	priorities  map[int]int       // Requests in flight per priority
+	observer    func(StatusEvent) // Notified of status changes (optional)
+}
+
+// EventCause is why a model changed status
+type EventCause string
+
+const (
+	CauseSwitch     EventCause = "switch"      // A switch, bootstrap or on-demand activation
+	CauseResync     EventCause = "resync"      // Resync with the vLLM server's actual state
+	CauseIdle       EventCause = "idle"        // Idle timeout elapsed
+	CauseError      EventCause = "error"       // An operation on the model failed
+	CauseSplitBrain EventCause = "split_brain" // Resync found more models awake than fit together
+	CauseThrashing  EventCause = "thrashing"   // Switches are alternating faster than the thrash detector allows
+	CauseSchedule   EventCause = "schedule"    // A sleep schedule fired
+)
+
+// StatusEvent describes a model status change
+type StatusEvent struct {
+	ModelID   string      `json:"model_id"`
+	OldStatus ModelStatus `json:"old_status"`
+	NewStatus ModelStatus `json:"new_status"`
+	Cause     EventCause  `json:"cause"`
+	Message   string      `json:"message,omitempty"` // Set on warnings, which don't change the status
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// SetObserver registers a function notified of every status change (thread-safe)
+func (m *Model) SetObserver(observer func(StatusEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observer = observer
+}
+
+// InheritState copies the runtime state (status, last active time, in-flight
+// requests and observer) of the model it replaces on a config reload (thread-safe)
+func (m *Model) InheritState(old *Model) {
+	old.mu.Lock()
+	status, lastActive, activatedAt, inflight, observer := old.status, old.lastActive, old.activatedAt, old.inflight, old.observer
+	priorities := make(map[int]int, len(old.priorities))
+	for p, n := range old.priorities {
+		priorities[p] = n
+	}
+	old.mu.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status = status
+	m.lastActive = lastActive
+	m.activatedAt = activatedAt
+	m.inflight = inflight
+	m.priorities = priorities
+	m.observer = observer
+}
+
+// Transition sets the status, refreshing the last active time when the model
+// becomes active, and notifies the observer if the status changed (thread-safe)
+func (m *Model) Transition(status ModelStatus, cause EventCause) {
+	m.mu.Lock()
+	old := m.status
+	m.status = status
+	now := time.Now()
+	if status == StatusActive {
+		m.lastActive = &now
+		if old != StatusActive {
+			m.activatedAt = &now
+		}
+	}
+	observer := m.observer
+	m.mu.Unlock()
+
+	// Notify outside the lock so observers may read the model
+	if observer != nil && old != status {
+		observer(StatusEvent{
+			ModelID:   m.ID,
+			OldStatus: old,
+			NewStatus: status,
+			Cause:     cause,
+			Timestamp: now,
+		})
+	}
 }
 
 // GetStatus returns the current status (thread-safe)
@@ -83,39 +174,114 @@ func (m *Model) SetLastActive(t *time.Time) {
 
 // MarkActive sets status to active and updates last active time (thread-safe)
 func (m *Model) MarkActive() {
+	m.Transition(StatusActive, CauseSwitch)
+}
+
+// Touch refreshes the last active time to now (thread-safe)
+func (m *Model) Touch() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.status = StatusActive
 	now := time.Now()
 	m.lastActive = &now
 }
 
-// MarkSleeping sets status to sleeping (thread-safe)
-func (m *Model) MarkSleeping() {
+// BeginRequest records an inference request of the given priority in flight and
+// refreshes last active time (thread-safe)
+func (m *Model) BeginRequest(priority int) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.status = StatusSleeping
+	m.inflight++
+	if m.priorities == nil {
+		m.priorities = make(map[int]int)
+	}
+	m.priorities[priority]++
+	now := time.Now()
+	m.lastActive = &now
 }
 
-// MarkSwitching sets status to switching (thread-safe)
-func (m *Model) MarkSwitching() {
+// EndRequest records a finished inference request of the given priority and
+// refreshes last active time (thread-safe)
+func (m *Model) EndRequest(priority int) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.status = StatusSwitching
+	if m.inflight > 0 {
+		m.inflight--
+	}
+	if m.priorities[priority] > 1 {
+		m.priorities[priority]--
+	} else {
+		delete(m.priorities, priority)
+	}
+	now := time.Now()
+	m.lastActive = &now
 }
 
-// MarkError sets status to error (thread-safe)
-func (m *Model) MarkError() {
+// BusyPriority returns the highest priority of the requests in flight. The
+// second return value is false when no request is in flight (thread-safe).
+func (m *Model) BusyPriority() (int, bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.status = StatusError
+
+	highest, busy := 0, false
+	for p := range m.priorities {
+		if !busy || p > highest {
+			highest, busy = p, true
+		}
+	}
+	return highest, busy
 }
 
-// MarkDisabled sets status to disabled (thread-safe)
-func (m *Model) MarkDisabled() {
+// ResidencyRemaining returns how much longer an active model must stay awake
+// to honor its min_residency, or 0 (thread-safe)
+func (m *Model) ResidencyRemaining(now time.Time) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.MinResidency <= 0 || m.status != StatusActive || m.activatedAt == nil {
+		return 0
+	}
+	return max(m.MinResidency-now.Sub(*m.activatedAt), 0)
+}
+
+// IdleRemaining returns the time left before the idle timeout elapses. The second
+// return value is false when the model is not subject to idle sleep (thread-safe).
+func (m *Model) IdleRemaining(now time.Time) (time.Duration, bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.status = StatusDisabled
+
+	if m.IdleTimeout <= 0 || m.status != StatusActive {
+		return 0, false
+	}
+	// Requests in flight keep the model busy; the countdown starts when they finish
+	if m.inflight > 0 || m.lastActive == nil {
+		return m.IdleTimeout, true
+	}
+
+	remaining := m.IdleTimeout - now.Sub(*m.lastActive)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true
+}
+
+// MarkSleeping sets status to sleeping (thread-safe)
+func (m *Model) MarkSleeping() {
+	m.Transition(StatusSleeping, CauseSwitch)
+}
+
+// MarkSwitching sets status to switching (thread-safe)
+func (m *Model) MarkSwitching() {
+	m.Transition(StatusSwitching, CauseSwitch)
+}
+
+// MarkError sets status to error (thread-safe)
+func (m *Model) MarkError() {
+	m.Transition(StatusError, CauseError)
+}
+
+// MarkDisabled sets status to disabled (thread-safe)
+func (m *Model) MarkDisabled() {
+	m.Transition(StatusDisabled, CauseSwitch)
 }
 
 // Snapshot returns a copy of the model with current state (thread-safe)
@@ -136,9 +302,15 @@ func (m *Model) Snapshot() Model {
 		Port:          m.Port,
 		HostPort:      m.HostPort,
 		GPUMemoryGB:   m.GPUMemoryGB,
+		GPUs:          m.GPUs,
 		StartupMode:   m.StartupMode,
+		IdleTimeout:   m.IdleTimeout,
+		MinResidency:  m.MinResidency,
+		Container:     m.Container,
 		status:        m.status,
 		lastActive:    lastActiveCopy,
+		activatedAt:   m.activatedAt,
+		inflight:      m.inflight,
 		// mu is intentionally NOT copied - each snapshot gets zero value
 	}
 }
@@ -154,9 +326,14 @@ func (m *Model) MarshalJSON() ([]byte, error) {
 		Port          int         `json:"port"`
 		HostPort      int         `json:"host_port"`
 		GPUMemoryGB   float64     `json:"gpu_memory_gb"`
+		GPUs          []int       `json:"gpus,omitempty"`
 		StartupMode   StartupMode `json:"startup_mode"`
 		Status        ModelStatus `json:"status"`
 		LastActive    *time.Time  `json:"last_active,omitempty"`
+		IdleTimeout   string      `json:"idle_timeout,omitempty"`
+		IdleRemaining *int64      `json:"idle_remaining_seconds,omitempty"`
+		MinResidency  string      `json:"min_residency,omitempty"`
+		Residency     int64       `json:"residency_remaining_seconds,omitempty"`
 	}
 
 	j := ModelJSON{
@@ -166,28 +343,379 @@ func (m *Model) MarshalJSON() ([]byte, error) {
 		Port:          snapshot.Port,
 		HostPort:      snapshot.HostPort,
 		GPUMemoryGB:   snapshot.GPUMemoryGB,
+		GPUs:          snapshot.GPUs,
 		StartupMode:   snapshot.StartupMode,
 		Status:        snapshot.status,
 		LastActive:    snapshot.lastActive,
 	}
 
+	if snapshot.IdleTimeout > 0 {
+		j.IdleTimeout = snapshot.IdleTimeout.String()
+	}
+	if remaining, ok := snapshot.IdleRemaining(time.Now()); ok {
+		seconds := int64(remaining.Seconds())
+		j.IdleRemaining = &seconds
+	}
+	if snapshot.MinResidency > 0 {
+		j.MinResidency = snapshot.MinResidency.String()
+		j.Residency = int64(math.Ceil(snapshot.ResidencyRemaining(time.Now()).Seconds()))
+	}
+
 	return json.Marshal(j)
 }
 
 // Config represents the application configuration
 type Config struct {
-	Models []Model `yaml:"models"`
+	Models            []Model       `yaml:"models"`
+	Queue             QueueConfig   `yaml:"queue"`
+	CircuitBreaker    BreakerConfig `yaml:"circuit_breaker"`
+	Auth              AuthConfig    `yaml:"auth"`
+	Thrash            ThrashConfig  `yaml:"thrash_detection"`
+	Schedules         []Schedule    `yaml:"schedules"`
+	GPUMemoryBudgetGB float64       `yaml:"gpu_memory_budget_gb"` // Total GPU memory awake models may use (0 = one active model)
+	GPUs              []GPUDevice   `yaml:"gpus"`                 // Per-device memory capacity (empty = no per-device tracking)
+}
+
+// ContainerConfig describes the container to create for a model that is started
+// on demand and has no existing container
+type ContainerConfig struct {
+	Image   string   `json:"image" yaml:"image"`
+	Command []string `json:"command,omitempty" yaml:"command"`
+	Env     []string `json:"env,omitempty" yaml:"env"`
+	Volumes []string `json:"volumes,omitempty" yaml:"volumes"` // host:container[:mode] bind mounts
+	Network string   `json:"network,omitempty" yaml:"network"`
+}
+
+// GPUDevice is a CUDA device and how much memory awake models may use on it
+type GPUDevice struct {
+	ID       int     `yaml:"id" json:"id"`
+	MemoryGB float64 `yaml:"memory_gb" json:"memory_gb"`
+}
+
+// DeviceShareGB returns the memory a model uses on each of its devices, assuming
+// tensor parallelism splits it evenly. numDevices is used when GPUs is empty.
+func (m *Model) DeviceShareGB(numDevices int) float64 {
+	if len(m.GPUs) > 0 {
+		numDevices = len(m.GPUs)
+	}
+	if numDevices == 0 {
+		return m.GPUMemoryGB
+	}
+	return m.GPUMemoryGB / float64(numDevices)
+}
+
+// QueueConfig bounds how inference requests are held while a model switch is in flight.
+// Zero values fall back to the switcher defaults.
+type QueueConfig struct {
+	MaxDepth       int `yaml:"max_depth"`        // Maximum queued requests per model
+	MaxWaitSeconds int `yaml:"max_wait_seconds"` // Maximum time a request waits for its model
+}
+
+// ThrashConfig detects switches alternating faster than they pay off. Once more
+// than MaxSwitches switches happen within the window, an alert is raised and
+// further switches are held back for the backoff.
+type ThrashConfig struct {
+	WindowSeconds  int `yaml:"window_seconds"`  // Sliding window switches are counted over (default 600)
+	MaxSwitches    int `yaml:"max_switches"`    // Switches allowed within the window (0 = detection off)
+	BackoffSeconds int `yaml:"backoff_seconds"` // How long switches are held once thrashing (0 = alert only)
+}
+
+// ScheduleAction is what a schedule does when it fires
+type ScheduleAction string
+
+const (
+	ScheduleSwitch ScheduleAction = "switch" // Switch to the schedule's model
+	ScheduleSleep  ScheduleAction = "sleep"  // Put every awake model to sleep
+)
+
+// Schedule switches models or puts them to sleep at set times. Each firing
+// starts a slot that lasts until the next firing of any schedule.
+type Schedule struct {
+	Name     string         `json:"name" yaml:"name"`
+	Cron     string         `json:"cron" yaml:"cron"`                   // Standard 5-field cron expression or descriptor like @daily
+	Timezone string         `json:"timezone,omitempty" yaml:"timezone"` // IANA time zone the expression is evaluated in (default: local)
+	Action   ScheduleAction `json:"action" yaml:"action"`               // switch (default) or sleep
+	ModelID  string         `json:"model_id,omitempty" yaml:"model_id"` // Model to switch to
+}
+
+// GetAction returns the schedule's action, defaulting to switch
+func (s *Schedule) GetAction() ScheduleAction {
+	if s.Action == "" {
+		return ScheduleSwitch
+	}
+	return s.Action
+}
+
+// Parse returns the firing times of the schedule's cron expression in its time zone
+func (s *Schedule) Parse() (cron.Schedule, error) {
+	loc := time.Local
+	if s.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(s.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", s.Timezone, err)
+		}
+	}
+
+	sched, err := cron.ParseStandard(s.Cron)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", s.Cron, err)
+	}
+	if spec, ok := sched.(*cron.SpecSchedule); ok {
+		spec.Location = loc
+	}
+	return sched, nil
+}
+
+// AuthConfig lists the API keys the manager accepts. With no keys, the API is
+// open except for the admin routes.
+type AuthConfig struct {
+	Keys []APIKey `yaml:"keys"`
+}
+
+// APIKey is a named bearer token. Only the token's hash is stored in the config.
+type APIKey struct {
+	Name     string `yaml:"name"` // Recorded with every action taken with the key
+	Hash     string `yaml:"hash"` // "sha256:" followed by the hex SHA-256 of the token
+	Role     Role   `yaml:"role"`
+	Priority int    `yaml:"priority"` // Default priority of the key's switches and inference requests
+}
+
+// Role determines which routes an API key may call. Each role includes the
+// ones below it.
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"   // Read model state, switch jobs and events
+	RoleOperator Role = "operator" // Switch models and send inference requests
+	RoleAdmin    Role = "admin"    // Change the model registry
+)
+
+// roleRank orders roles from least to most privileged
+var roleRank = map[Role]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	return roleRank[r] > 0
+}
+
+// Includes reports whether r grants everything required grants
+func (r Role) Includes(required Role) bool {
+	return r.Valid() && roleRank[r] >= roleRank[required]
+}
+
+// BreakerConfig tunes the circuit breaker kept per vLLM server.
+// Zero values fall back to the vllm package defaults.
+type BreakerConfig struct {
+	FailureThreshold int `yaml:"failure_threshold"` // Consecutive failed calls that open the circuit
+	OpenSeconds      int `yaml:"open_seconds"`      // How long the circuit stays open before a probe call is let through
+}
+
+// CircuitState is the state of a vLLM server's circuit breaker
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // Calls go through
+	CircuitOpen     CircuitState = "open"      // Calls fail fast
+	CircuitHalfOpen CircuitState = "half_open" // The next call is a probe deciding whether to close again
+)
+
+// CircuitStatus reports a vLLM server's circuit breaker
+type CircuitStatus struct {
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
 }
 
 // SwitchRequest is the request body for switching models
 type SwitchRequest struct {
-	ModelID string `json:"model_id" binding:"required"`
+	ModelID  string `json:"model_id" binding:"required"`
+	Async    bool   `json:"async"`              // Return 202 with a job ID instead of waiting for the switch
+	Priority *int   `json:"priority,omitempty"` // Overrides the API key's priority, up to that priority
+	Force    bool   `json:"force,omitempty"`    // Preempt higher-priority work (admin keys only)
+}
+
+// ModelRequest is the request body for creating or updating a model through the
+// admin API; the model ID comes from the URL
+type ModelRequest struct {
+	Name          string           `json:"name"`
+	ContainerName string           `json:"container_name" binding:"required"`
+	Port          int              `json:"port" binding:"required"`
+	HostPort      int              `json:"host_port"`
+	GPUMemoryGB   float64          `json:"gpu_memory_gb"`
+	GPUs          []int            `json:"gpus"`
+	StartupMode   StartupMode      `json:"startup_mode" binding:"required"`
+	IdleTimeout   string           `json:"idle_timeout"`  // Go duration such as "30m" (empty = never)
+	MinResidency  string           `json:"min_residency"` // Go duration such as "2m" (empty = no minimum)
+	Container     *ContainerConfig `json:"container"`
+}
+
+// ToModel builds the model described by the request
+func (r ModelRequest) ToModel(id string) (*Model, error) {
+	var idleTimeout time.Duration
+	if r.IdleTimeout != "" {
+		d, err := time.ParseDuration(r.IdleTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid idle_timeout %q: %w", r.IdleTimeout, err)
+		}
+		idleTimeout = d
+	}
+	var minResidency time.Duration
+	if r.MinResidency != "" {
+		d, err := time.ParseDuration(r.MinResidency)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min_residency %q: %w", r.MinResidency, err)
+		}
+		minResidency = d
+	}
+
+	return &Model{
+		ID:            id,
+		Name:          r.Name,
+		ContainerName: r.ContainerName,
+		Port:          r.Port,
+		HostPort:      r.HostPort,
+		GPUMemoryGB:   r.GPUMemoryGB,
+		GPUs:          r.GPUs,
+		StartupMode:   r.StartupMode,
+		IdleTimeout:   idleTimeout,
+		MinResidency:  minResidency,
+		Container:     r.Container,
+	}, nil
+}
+
+// SwitchJobPhase is the stage an asynchronous switch job has reached
+type SwitchJobPhase string
+
+const (
+	PhaseQueued          SwitchJobPhase = "queued"           // Waiting for another switch to finish
+	PhaseDeferred        SwitchJobPhase = "deferred"         // Waiting for higher-priority work or a cooldown to end
+	PhaseSleepingCurrent SwitchJobPhase = "sleeping-current" // Putting evicted models to sleep
+	PhaseWakingTarget    SwitchJobPhase = "waking-target"    // Waking (or starting) the target
+	PhaseHealthChecking  SwitchJobPhase = "health-checking"  // Polling the target's health endpoint
+	PhaseDone            SwitchJobPhase = "done"
+	PhaseFailed          SwitchJobPhase = "failed"
+	PhaseCanceled        SwitchJobPhase = "canceled" // Aborted and rolled back
+)
+
+// SwitchJob reports the progress of an asynchronous switch
+type SwitchJob struct {
+	ID             string         `json:"id"`
+	ModelID        string         `json:"model_id"`
+	Phase          SwitchJobPhase `json:"phase"`
+	Attempt        int            `json:"attempt,omitempty"`      // Current health check attempt
+	MaxAttempts    int            `json:"max_attempts,omitempty"` // Health check attempts before giving up
+	StartedAt      time.Time      `json:"started_at"`
+	FinishedAt     *time.Time     `json:"finished_at,omitempty"`
+	ElapsedSeconds float64        `json:"elapsed_seconds"`
+	Error          string         `json:"error,omitempty"`
+}
+
+// SwitchRecord is a completed switch kept in the persistent switch history
+type SwitchRecord struct {
+	ModelID    string    `json:"model_id"`
+	From       []string  `json:"from"` // Models that were active when the switch started
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// AuditAction is the kind of event an audit log entry records
+type AuditAction string
+
+const (
+	AuditSwitch     AuditAction = "switch"      // A switch finished, successfully or not
+	AuditResync     AuditAction = "resync"      // Resync corrected a model's status to the vLLM server's actual state
+	AuditSplitBrain AuditAction = "split_brain" // Resync put a model to sleep that didn't fit alongside the others
+	AuditSleep      AuditAction = "sleep"       // A schedule put a model to sleep
+)
+
+// AuditEntry is one line of the append-only audit log
+type AuditEntry struct {
+	Time            time.Time   `json:"time"`
+	Action          AuditAction `json:"action"`
+	Actor           string      `json:"actor"` // API key name, "anonymous" while auth is off, or "system"
+	ModelID         string      `json:"model_id"`
+	From            []string    `json:"from,omitempty"`       // Models awake when the switch started
+	OldStatus       ModelStatus `json:"old_status,omitempty"` // Status before a resync correction
+	NewStatus       ModelStatus `json:"new_status,omitempty"` // Status after a resync correction
+	DurationSeconds float64     `json:"duration_seconds,omitempty"`
+	Success         bool        `json:"success"`
+	RolledBack      bool        `json:"rolled_back,omitempty"` // A failed switch woke the models it had put to sleep
+	Priority        int         `json:"priority,omitempty"`
+	Forced          bool        `json:"forced,omitempty"` // Requested with force, allowed to preempt higher-priority work
+	Error           string      `json:"error,omitempty"`
 }
 
 // ModelsResponse is the response for listing models
 type ModelsResponse struct {
-	Models      []Model `json:"models"`
-	ActiveModel string  `json:"active_model"`
+	Models            []Model       `json:"models"`
+	ActiveModel       string        `json:"active_model"`  // Most recently switched-to model
+	ActiveModels      []string      `json:"active_models"` // Every awake model
+	GPUMemoryBudgetGB float64       `json:"gpu_memory_budget_gb,omitempty"`
+	GPUMemoryUsedGB   float64       `json:"gpu_memory_used_gb"`
+	Devices           []DeviceUsage `json:"devices,omitempty"` // Per-GPU occupancy, when devices are configured
+
+	Circuits map[string]CircuitStatus `json:"circuits,omitempty"` // Circuit breaker per model, when enabled
+
+	SwitchBackoffSeconds int64 `json:"switch_backoff_seconds,omitempty"` // Time left before switches resume after thrashing
+}
+
+// ScheduleFiring is a time a schedule fires
+type ScheduleFiring struct {
+	Schedule string         `json:"schedule"`
+	Action   ScheduleAction `json:"action"`
+	ModelID  string         `json:"model_id,omitempty"`
+	Time     time.Time      `json:"time"`
+}
+
+// ScheduleOverride is a manual switch that suspends the schedules until the
+// next slot starts
+type ScheduleOverride struct {
+	Actor   string    `json:"actor"`
+	ModelID string    `json:"model_id"`
+	Since   time.Time `json:"since"`
+	Until   time.Time `json:"until"` // Start of the next slot
+}
+
+// SchedulesResponse is the response for listing schedules
+type SchedulesResponse struct {
+	Schedules []Schedule        `json:"schedules"`
+	Current   *ScheduleFiring   `json:"current,omitempty"`  // Firing that started the slot in effect
+	Override  *ScheduleOverride `json:"override,omitempty"` // Manual switch suspending the current slot
+	Upcoming  []ScheduleFiring  `json:"upcoming"`           // Next firings of all schedules, soonest first
+}
+
+// DeviceUsage reports how much of a GPU's memory awake models occupy
+type DeviceUsage struct {
+	ID       int      `json:"id"`
+	MemoryGB float64  `json:"memory_gb"`
+	UsedGB   float64  `json:"used_gb"`
+	Models   []string `json:"models"`
+}
+
+// OpenAIModelList is the response for the OpenAI-compatible /v1/models endpoint
+type OpenAIModelList struct {
+	Object string        `json:"object"`
+	Data   []OpenAIModel `json:"data"`
+}
+
+// OpenAIModel is a single entry of OpenAIModelList, extended with switcher state
+type OpenAIModel struct {
+	ID       string              `json:"id"`
+	Object   string              `json:"object"`
+	Created  int64               `json:"created"`
+	OwnedBy  string              `json:"owned_by"`
+	Metadata OpenAIModelMetadata `json:"metadata"`
+}
+
+// OpenAIModelMetadata carries the live switcher state of a model
+type OpenAIModelMetadata struct {
+	Name        string      `json:"name"`
+	Status      ModelStatus `json:"status"`
+	GPUMemoryGB float64     `json:"gpu_memory_gb"`
+	LastActive  *time.Time  `json:"last_active,omitempty"`
 }
 
 // HealthResponse is a simple health check response